@@ -0,0 +1,412 @@
+/*
+api.go provides a collector building cluster information from the Redis Enterprise REST API
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nic-gibson/go-bytesize"
+)
+
+// APIOptions control how cluster information is collected from the REST API
+type APIOptions struct {
+	Username string
+	Password string
+	// TLSConfig is used for https connections. If nil, the default configuration is used.
+	TLSConfig *tls.Config
+	// Insecure skips verification of the server certificate, which is self signed by default
+	Insecure bool
+	// Client replaces the client built from the TLS options if set
+	Client *http.Client
+}
+
+// apiId is a uid which the REST API returns as either a number or a string
+type apiId uint64
+
+type apiCluster struct {
+	Name string `json:"name"`
+}
+
+type apiNode struct {
+	Uid             apiId    `json:"uid"`
+	Addr            string   `json:"addr"`
+	ExternalAddr    []string `json:"external_addr"`
+	Status          string   `json:"status"`
+	SoftwareVersion string   `json:"software_version"`
+	RackId          string   `json:"rack_id"`
+	Cores           uint16   `json:"cores"`
+	TotalMemory     uint64   `json:"total_memory"`
+	MaxRedisServers uint16   `json:"max_redis_servers"`
+	ShardCount      uint16   `json:"shard_count"`
+}
+
+// apiNodeStats is the latest statistics interval of a node, in bytes
+type apiNodeStats struct {
+	FreeMemory        float64 `json:"free_memory"`
+	ProvisionalMemory float64 `json:"provisional_memory"`
+}
+
+// apiShardStats is the latest statistics interval of a shard, in bytes
+type apiShardStats struct {
+	UsedMemory float64 `json:"used_memory"`
+}
+
+type apiDatabaseEndpoint struct {
+	DNSAddress string `json:"dns_address"`
+	Port       uint16 `json:"port"`
+}
+
+type apiDatabase struct {
+	Uid             apiId                 `json:"uid"`
+	Name            string                `json:"name"`
+	Type            string                `json:"type"`
+	Status          string                `json:"status"`
+	ShardsCount     uint16                `json:"shards_count"`
+	ShardsPlacement string                `json:"shards_placement"`
+	Replication     bool                  `json:"replication"`
+	DataPersistence string                `json:"data_persistence"`
+	Version         string                `json:"version"`
+	Endpoints       []apiDatabaseEndpoint `json:"endpoints"`
+}
+
+type apiShard struct {
+	Uid           apiId  `json:"uid"`
+	BdbUid        apiId  `json:"bdb_uid"`
+	NodeUid       apiId  `json:"node_uid"`
+	Role          string `json:"role"`
+	AssignedSlots string `json:"assigned_slots"`
+	Status        string `json:"status"`
+}
+
+type apiEndpoint struct {
+	Uid         string   `json:"uid"`
+	BdbUid      apiId    `json:"bdb_uid"`
+	Addr        []string `json:"addr"`
+	ProxyPolicy string   `json:"proxy_policy"`
+}
+
+// apiClient fetches objects from the REST API
+type apiClient struct {
+	base   *url.URL
+	opts   APIOptions
+	client *http.Client
+}
+
+// NewClusterInfoFromAPI collects cluster information from the REST API of a Redis Enterprise
+// cluster, such as https://cluster.example.com:9443. If key is empty, the cluster name is used.
+//
+// Free and provisional memory for nodes and used memory for shards are taken from the latest
+// statistics. The REST API doesn't report everything rladmin status does. The cluster master
+// and node roles aren't available from the objects collected, so the cluster status is nil
+// and node roles are left empty. The total provisional memory isn't reported either, so it is
+// left at zero and provisional utilisation can't be calculated.
+func NewClusterInfoFromAPI(ctx context.Context, key, baseURL string, opts APIOptions) (*ClusterInfo, error) {
+	api, err := newAPIClient(baseURL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var cluster apiCluster
+	var nodes []apiNode
+	var databases []apiDatabase
+	var shards []apiShard
+	var endpoints []apiEndpoint
+	var nodeStats map[string]apiNodeStats
+	var shardStats map[string]apiShardStats
+
+	for _, request := range []struct {
+		path   string
+		target any
+	}{
+		{"/v1/cluster", &cluster},
+		{"/v1/nodes", &nodes},
+		{"/v1/bdbs", &databases},
+		{"/v1/shards", &shards},
+		{"/v1/endpoints", &endpoints},
+		{"/v1/nodes/stats/last", &nodeStats},
+		{"/v1/shards/stats/last", &shardStats},
+	} {
+		if err := api.get(ctx, request.path, request.target); err != nil {
+			return nil, err
+		}
+	}
+
+	if key == "" {
+		key = cluster.Name
+	}
+	info := &ClusterInfo{Key: key, TimeStamp: time.Now().UTC()}
+	info.Nodes, err = apiNodes(info, nodes, nodeStats)
+	if err != nil {
+		return nil, err
+	}
+	info.Databases, err = apiDatabases(info, databases)
+	if err != nil {
+		return nil, err
+	}
+
+	// shards and endpoints take their names from the databases
+	info.Reindex()
+	info.Shards, err = apiShards(info, shards, shardStats)
+	if err != nil {
+		return nil, err
+	}
+	info.Endpoints, err = apiEndpoints(info, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Reindex()
+
+	// master and replica counts aren't in the node objects
+	for _, shard := range info.Shards {
+		if node := info.Node(shard.NodeId); node != nil {
+			if shard.Role == ShardRoleMaster {
+				node.Masters++
+			} else if shard.Role == ShardRoleReplica {
+				node.Replicas++
+			}
+		}
+	}
+
+	return info, nil
+}
+
+func newAPIClient(baseURL string, opts APIOptions) (*apiClient, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf(errorString, baseURL, "REST API url", err)
+	}
+
+	client := opts.Client
+	if client == nil {
+		config := &tls.Config{}
+		if opts.TLSConfig != nil {
+			config = opts.TLSConfig.Clone()
+		}
+		if opts.Insecure {
+			config.InsecureSkipVerify = true
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		client = &http.Client{Transport: transport}
+	}
+
+	return &apiClient{base: base, opts: opts, client: client}, nil
+}
+
+// get fetches path and decodes the JSON response into target
+func (a *apiClient) get(ctx context.Context, path string, target any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, a.base.JoinPath(path).String(), nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if a.opts.Username != "" || a.opts.Password != "" {
+		request.SetBasicAuth(a.opts.Username, a.opts.Password)
+	}
+
+	response, err := a.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("rlatool - unable to fetch %s: %w", path, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("rlatool - unable to fetch %s: %s %s", path, response.Status, bytes.TrimSpace(body))
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(target); err != nil {
+		return fmt.Errorf(errorString, path, "REST API response", err)
+	}
+	return nil
+}
+
+func apiNodes(parent *ClusterInfo, nodes []apiNode, stats map[string]apiNodeStats) (Nodes, error) {
+	converted := Nodes{}
+	for _, n := range nodes {
+		stat := stats[strconv.FormatUint(uint64(n.Uid), 10)]
+		node := &Node{
+			Key:            parent.Key,
+			Id:             NodeID(n.Uid),
+			Cores:          n.Cores,
+			Version:        n.SoftwareVersion,
+			RackId:         n.RackId,
+			Status:         apiStatus(n.Status, NodeStatusOK, nodeStatuses),
+			ShardUsage:     ShardInfo{InUse: n.ShardCount, Max: n.MaxRedisServers},
+			RedisRAM:       MemoryInfo{Free: apiMemory(stat.FreeMemory), Max: apiMemory(float64(n.TotalMemory))},
+			ProvisionalRAM: MemoryInfo{Free: apiMemory(stat.ProvisionalMemory)},
+			TimeStamp:      parent.TimeStamp,
+			parent:         parent,
+		}
+		if err := apiField(&node.Address, n.Addr, "node address"); err != nil {
+			return nil, err
+		}
+		if len(n.ExternalAddr) > 0 {
+			if err := apiField(&node.ExternalAddress, n.ExternalAddr[0], "node external address"); err != nil {
+				return nil, err
+			}
+		}
+		node.Quorum = node.ShardUsage.Max == 0
+		converted = append(converted, node)
+	}
+	return converted, nil
+}
+
+func apiDatabases(parent *ClusterInfo, databases []apiDatabase) (Databases, error) {
+	converted := Databases{}
+	for _, d := range databases {
+		db := &Database{
+			Key:          parent.Key,
+			Id:           DBID(d.Uid),
+			Name:         d.Name,
+			Type:         d.Type,
+			MasterShards: d.ShardsCount,
+			Endpoint:     DBEndPoints{},
+			ExecState:    ExecStateNone,
+			RedisVersion: d.Version,
+			TimeStamp:    parent.TimeStamp,
+			parent:       parent,
+		}
+		for _, field := range []struct {
+			target encoding.TextUnmarshaler
+			value  string
+			name   string
+		}{
+			{&db.Status, d.Status, "database status"},
+			{&db.Placement, d.ShardsPlacement, "shard placement"},
+			{&db.Persistence, d.DataPersistence, "data persistence"},
+		} {
+			if err := apiField(field.target, field.value, field.name); err != nil {
+				return nil, err
+			}
+		}
+		db.Replication = ReplicationDisabled
+		if d.Replication {
+			db.Replication = ReplicationEnabled
+		}
+		for _, endpoint := range d.Endpoints {
+			db.Endpoint = append(db.Endpoint, endpoint.DNSAddress+":"+strconv.Itoa(int(endpoint.Port)))
+		}
+		converted = append(converted, db)
+	}
+	return converted, nil
+}
+
+func apiShards(parent *ClusterInfo, shards []apiShard, stats map[string]apiShardStats) (Shards, error) {
+	converted := Shards{}
+	for _, s := range shards {
+		stat := stats[strconv.FormatUint(uint64(s.Uid), 10)]
+		shard := &Shard{
+			Key:            parent.Key,
+			Id:             ShardID(s.Uid),
+			DBId:           DBID(s.BdbUid),
+			NodeId:         NodeID(s.NodeUid),
+			Status:         apiStatus(s.Status, ShardStatusOK, shardStatuses),
+			UsedMemory:     apiMemory(stat.UsedMemory),
+			WatchdogStatus: WatchdogStatusOK,
+			TimeStamp:      parent.TimeStamp,
+			parent:         parent,
+		}
+		if err := apiField(&shard.Role, s.Role, "shard role"); err != nil {
+			return nil, err
+		}
+		if s.AssignedSlots != "" {
+			if err := apiField(&shard.Slots, s.AssignedSlots, "assigned slots"); err != nil {
+				return nil, err
+			}
+		}
+		if db := parent.Database(shard.DBId); db != nil {
+			shard.Name = db.Name
+		}
+		converted = append(converted, shard)
+	}
+	return converted, nil
+}
+
+// apiEndpoints returns an endpoint for each node the endpoint is bound to, as rladmin does.
+// Nodes are found from the addresses of the endpoint.
+func apiEndpoints(parent *ClusterInfo, endpoints []apiEndpoint) (Endpoints, error) {
+	converted := Endpoints{}
+	for _, e := range endpoints {
+		id, err := ParseEndpointID(e.Uid)
+		if err != nil {
+			return nil, err
+		}
+
+		template := Endpoint{
+			Key:            parent.Key,
+			Id:             id,
+			DBId:           DBID(e.BdbUid),
+			WatchdogStatus: WatchdogStatusOK,
+			TimeStamp:      parent.TimeStamp,
+			parent:         parent,
+		}
+		if err := apiField(&template.Role, e.ProxyPolicy, "proxy policy"); err != nil {
+			return nil, err
+		}
+		if db := parent.Database(template.DBId); db != nil {
+			template.Name = db.Name
+		}
+
+		for _, node := range parent.Nodes {
+			if slices.Contains(e.Addr, node.Address.String()) || slices.Contains(e.Addr, node.ExternalAddress.String()) {
+				endpoint := template
+				endpoint.NodeId = node.Id
+				converted = append(converted, &endpoint)
+			}
+		}
+	}
+	return converted, nil
+}
+
+// apiField sets a field from a value in a REST API object
+func apiField(target encoding.TextUnmarshaler, value, name string) error {
+	if err := target.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf(errorString, value, name, err)
+	}
+	return nil
+}
+
+// apiStatus converts the status of a node or shard. The REST API reports "active" where
+// rladmin reports OK. Other statuses, such as provisioning or decommissioning nodes, are
+// kept as reported so IsUnknown is true for any rladmin doesn't use.
+func apiStatus[T ~string](status string, ok T, known []T) T {
+	if strings.EqualFold(status, "active") {
+		return ok
+	}
+	return parseEnum([]byte(status), known)
+}
+
+// apiMemory converts a size in bytes to GB
+func apiMemory(size float64) RAMFloat {
+	return RAMFloat(size / float64(bytesize.GB))
+}
+
+func (id *apiId) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		*id = 0
+		return nil
+	}
+	v, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf(errorString, text, "uid", err)
+	}
+	*id = apiId(v)
+	return nil
+}
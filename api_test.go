@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"context"
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/api
+var apiResponses embed.FS
+
+// apiServer serves the recorded REST API responses
+func apiServer(t *testing.T, tls bool) *httptest.Server {
+	return apiServerWith(t, tls, nil)
+}
+
+// apiServerWith serves the recorded REST API responses, replacing any named in
+// replace with the text given
+func apiServerWith(t *testing.T, tls bool, replace map[string]string) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin@example.com" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// /v1/nodes/stats/last is served from nodes_stats_last.json
+		name := strings.ReplaceAll(strings.TrimPrefix(r.URL.Path, "/v1/"), "/", "_")
+		if text, ok := replace[name]; ok {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(text))
+			return
+		}
+		data, err := apiResponses.ReadFile(path.Join("testdata/api", name+".json"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+
+	if tls {
+		server := httptest.NewTLSServer(handler)
+		t.Cleanup(server.Close)
+		return server
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClusterInfoFromAPI(t *testing.T) {
+	server := apiServer(t, true)
+	opts := APIOptions{Username: "admin@example.com", Password: "secret", Insecure: true}
+
+	info, err := NewClusterInfoFromAPI(context.Background(), "", server.URL, opts)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, "cluster.example.com", info.Key)
+	assert.Nil(t, info.Cluster)
+	if assert.Len(t, info.Nodes, 3) {
+		node := info.Node(1)
+		assert.Equal(t, "10.0.0.1", node.Address.String())
+		assert.Equal(t, "203.0.113.1", node.ExternalAddress.String())
+		assert.Equal(t, NodeStatusOK, node.Status)
+		assert.Equal(t, uint16(1), node.Masters)
+		assert.InDelta(t, 31.25, float64(node.RedisRAM.Max), 0.001)
+		assert.InDelta(t, 20, float64(node.RedisRAM.Free), 0.001)
+		assert.InDelta(t, 12, float64(node.ProvisionalRAM.Free), 0.001)
+		assert.Equal(t, NodeStatusDown, info.Node(3).Status)
+		assert.True(t, info.Node(3).Quorum)
+	}
+
+	db := info.Database(1)
+	if assert.NotNil(t, db) {
+		assert.Equal(t, ReplicationEnabled, db.Replication)
+		assert.Equal(t, PersistenceAOF, db.Persistence)
+		assert.Equal(t, DBEndPoints{"redis-12000.cluster.example.com:12000"}, db.Endpoint)
+		assert.Len(t, db.Shards(), 2)
+		assert.Len(t, db.Shards().Pairs(), 1)
+	}
+
+	shard := info.Shard(2)
+	if assert.NotNil(t, shard) {
+		assert.Equal(t, ShardRoleReplica, shard.Role)
+		assert.Equal(t, "orders", shard.Name)
+		assert.Equal(t, NodeID(2), shard.Node().Id)
+		assert.InDelta(t, 1.488, float64(shard.UsedMemory), 0.001)
+	}
+
+	// capacity analysis works from the statistics
+	capacity := info.Capacity()
+	assert.InDelta(t, 11.25, float64(capacity.Nodes[0].UsedRAM), 0.001)
+	assert.True(t, capacity.Fit(4, 1, true).Fits)
+
+	if assert.Len(t, info.Endpoints, 1) {
+		assert.Equal(t, EndpointID{DB: 1, Number: 1}, info.Endpoints[0].Id)
+		assert.Equal(t, NodeID(1), info.Endpoints[0].NodeId)
+		assert.Equal(t, PolicySingle, info.Endpoints[0].Role)
+	}
+
+	_, err = info.Nodes.CSV(false)
+	assert.Nil(t, err)
+}
+
+func TestClusterInfoFromAPIErrors(t *testing.T) {
+	server := apiServer(t, true)
+
+	// the test server certificate isn't trusted
+	_, err := NewClusterInfoFromAPI(context.Background(), "test", server.URL, APIOptions{Username: "admin@example.com", Password: "secret"})
+	assert.NotNil(t, err)
+
+	_, err = NewClusterInfoFromAPI(context.Background(), "test", server.URL, APIOptions{Username: "admin@example.com", Password: "wrong", Client: server.Client()})
+	assert.ErrorContains(t, err, "401")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewClusterInfoFromAPI(ctx, "test", apiServer(t, false).URL, APIOptions{Username: "admin@example.com", Password: "secret"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClusterInfoFromAPIValues(t *testing.T) {
+	opts := APIOptions{Username: "admin@example.com", Password: "secret"}
+
+	// statuses rladmin doesn't use are kept so IsUnknown reports them
+	server := apiServerWith(t, false, map[string]string{
+		"nodes": `[{"uid": 1, "addr": "10.0.0.1", "status": "provisioning"}, {"uid": 2, "addr": "10.0.0.2", "status": "Down"}]`,
+		"bdbs":  `[{"uid": 1, "name": "orders", "status": "import-pending", "shards_placement": "sparse", "data_persistence": "snapshot"}]`,
+	})
+	info, err := NewClusterInfoFromAPI(context.Background(), "test", server.URL, opts)
+	if assert.Nil(t, err) {
+		assert.Equal(t, NodeStatus("provisioning"), info.Node(1).Status)
+		assert.True(t, info.Node(1).Status.IsUnknown())
+		assert.Equal(t, NodeStatusDown, info.Node(2).Status)
+		assert.Equal(t, DatabaseStatusImportPending, info.Database(1).Status)
+		assert.Equal(t, PlacementSparse, info.Database(1).Placement)
+		assert.Equal(t, PersistenceSnapshot, info.Database(1).Persistence)
+		assert.Equal(t, "orders", info.Shard(1).Name)
+	}
+
+	// values which can't be parsed are errors rather than being dropped
+	server = apiServerWith(t, false, map[string]string{
+		"nodes": `[{"uid": 1, "addr": "10.0.0.X", "status": "active"}]`,
+	})
+	_, err = NewClusterInfoFromAPI(context.Background(), "test", server.URL, opts)
+	assert.ErrorContains(t, err, "unable to parse '10.0.0.X' as node address")
+
+	server = apiServerWith(t, false, map[string]string{
+		"shards": `[{"uid": 1, "bdb_uid": 1, "node_uid": 1, "role": "master", "assigned_slots": "0-99999", "status": "active"}]`,
+	})
+	_, err = NewClusterInfoFromAPI(context.Background(), "test", server.URL, opts)
+	assert.ErrorContains(t, err, "as assigned slots")
+}
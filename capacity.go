@@ -0,0 +1,223 @@
+/*
+capacity.go provides a capacity planning report for the cluster and its nodes
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gocarina/gocsv"
+)
+
+// NodeCapacity is the RAM and shard usage of a node. Utilisation values are percentages.
+type NodeCapacity struct {
+	Id                     NodeID     `json:"id" csv:"id"`
+	RackId                 string     `json:"rackId" csv:"rackId"`
+	Status                 NodeStatus `json:"status" csv:"status"`
+	UsedRAM                RAMFloat   `json:"usedRAM" csv:"usedRAM"`
+	FreeRAM                RAMFloat   `json:"freeRAM" csv:"freeRAM"`
+	MaxRAM                 RAMFloat   `json:"maxRAM" csv:"maxRAM"`
+	ProvisionalFree        RAMFloat   `json:"provisionalFree" csv:"provisionalFree"`
+	ProvisionalMax         RAMFloat   `json:"provisionalMax" csv:"provisionalMax"`
+	Shards                 int        `json:"shards" csv:"shards"`
+	MaxShards              int        `json:"maxShards" csv:"maxShards"`
+	OverbookingDepth       RAMFloat   `json:"overbookingDepth" csv:"overbookingDepth"`
+	RAMUtilisation         float64    `json:"ramUtilisation" csv:"ramUtilisation"`
+	ProvisionalUtilisation float64    `json:"provisionalUtilisation" csv:"provisionalUtilisation"`
+	ShardUtilisation       float64    `json:"shardUtilisation" csv:"shardUtilisation"`
+	Quorum                 bool       `json:"quorum" csv:"quorum"`
+}
+
+type NodeCapacities []*NodeCapacity
+
+// CapacityReport sums node capacity across the cluster. MostConstrained is the node, other
+// than quorum only nodes, with the highest provisional RAM or shard utilisation.
+type CapacityReport struct {
+	Key                    string         `json:"key"`
+	TimeStamp              time.Time      `json:"timeStamp"`
+	UsedRAM                RAMFloat       `json:"usedRAM"`
+	FreeRAM                RAMFloat       `json:"freeRAM"`
+	MaxRAM                 RAMFloat       `json:"maxRAM"`
+	ProvisionalFree        RAMFloat       `json:"provisionalFree"`
+	ProvisionalMax         RAMFloat       `json:"provisionalMax"`
+	Shards                 int            `json:"shards"`
+	MaxShards              int            `json:"maxShards"`
+	RAMUtilisation         float64        `json:"ramUtilisation"`
+	ProvisionalUtilisation float64        `json:"provisionalUtilisation"`
+	ShardUtilisation       float64        `json:"shardUtilisation"`
+	MostConstrained        NodeID         `json:"mostConstrained"`
+	Nodes                  NodeCapacities `json:"nodes"`
+}
+
+// PlacedShard is a shard of a new database and the node it would be placed on
+type PlacedShard struct {
+	Shard int       `json:"shard"`
+	Role  ShardRole `json:"role"`
+	Node  NodeID    `json:"node"`
+	Size  RAMFloat  `json:"size"`
+}
+
+// Placement is the result of trying to fit a new database into the cluster
+type Placement struct {
+	Fits   bool          `json:"fits"`
+	Reason string        `json:"reason,omitempty"`
+	Shards []PlacedShard `json:"shards"`
+}
+
+// Capacity returns the capacity report for the cluster
+func (c *ClusterInfo) Capacity() *CapacityReport {
+	report := &CapacityReport{Key: c.Key, TimeStamp: c.TimeStamp, Nodes: NodeCapacities{}}
+	constraint := -1.0
+
+	for _, node := range c.Nodes {
+		capacity := &NodeCapacity{
+			Id:               node.Id,
+			RackId:           node.RackId,
+			Status:           node.Status,
+			UsedRAM:          node.RedisRAM.Max - node.RedisRAM.Free,
+			FreeRAM:          node.RedisRAM.Free,
+			MaxRAM:           node.RedisRAM.Max,
+			ProvisionalFree:  node.ProvisionalRAM.Free,
+			ProvisionalMax:   node.ProvisionalRAM.Max,
+			Shards:           int(node.ShardUsage.InUse),
+			MaxShards:        int(node.ShardUsage.Max),
+			OverbookingDepth: node.OverbookingDepth,
+			Quorum:           node.Quorum,
+		}
+		capacity.RAMUtilisation = percentage(float64(capacity.UsedRAM), float64(capacity.MaxRAM))
+		capacity.ProvisionalUtilisation = percentage(float64(capacity.ProvisionalMax-capacity.ProvisionalFree), float64(capacity.ProvisionalMax))
+		capacity.ShardUtilisation = percentage(float64(capacity.Shards), float64(capacity.MaxShards))
+		report.Nodes = append(report.Nodes, capacity)
+
+		report.UsedRAM += capacity.UsedRAM
+		report.FreeRAM += capacity.FreeRAM
+		report.MaxRAM += capacity.MaxRAM
+		report.ProvisionalFree += capacity.ProvisionalFree
+		report.ProvisionalMax += capacity.ProvisionalMax
+		report.Shards += capacity.Shards
+		report.MaxShards += capacity.MaxShards
+
+		if !capacity.Quorum {
+			if worst := max(capacity.ProvisionalUtilisation, capacity.ShardUtilisation); worst > constraint {
+				constraint = worst
+				report.MostConstrained = capacity.Id
+			}
+		}
+	}
+
+	report.RAMUtilisation = percentage(float64(report.UsedRAM), float64(report.MaxRAM))
+	report.ProvisionalUtilisation = percentage(float64(report.ProvisionalMax-report.ProvisionalFree), float64(report.ProvisionalMax))
+	report.ShardUtilisation = percentage(float64(report.Shards), float64(report.MaxShards))
+
+	return report
+}
+
+// Fit tries to place a new database of size GB split over the given number of master shards,
+// with a replica for each if replication is true. Each shard is placed on the node with the most
+// provisional RAM free which has a shard available; replicas are never placed on the node of their
+// master and are kept out of its rack if possible. Nodes which are not OK are not used.
+func (r *CapacityReport) Fit(size RAMFloat, shards int, replication bool) *Placement {
+	placement := &Placement{Shards: []PlacedShard{}}
+	if shards < 1 {
+		placement.Reason = "a database needs at least one shard"
+		return placement
+	}
+
+	shardSize := size / RAMFloat(shards)
+	free := map[NodeID]RAMFloat{}
+	slots := map[NodeID]int{}
+	candidates := NodeCapacities{}
+	for _, node := range r.Nodes {
+		if node.Status.IsHealthy() && node.MaxShards > node.Shards {
+			free[node.Id] = node.ProvisionalFree
+			slots[node.Id] = node.MaxShards - node.Shards
+			candidates = append(candidates, node)
+		}
+	}
+
+	place := func(shard int, role ShardRole, avoid *NodeCapacity) *NodeCapacity {
+		var best *NodeCapacity
+		for _, node := range candidates {
+			if slots[node.Id] == 0 || free[node.Id] < shardSize || node == avoid {
+				continue
+			}
+			if best == nil || betterNode(node, best, avoid, free) {
+				best = node
+			}
+		}
+		if best != nil {
+			free[best.Id] -= shardSize
+			slots[best.Id]--
+			placement.Shards = append(placement.Shards, PlacedShard{Shard: shard, Role: role, Node: best.Id, Size: shardSize})
+		}
+		return best
+	}
+
+	for shard := 1; shard <= shards; shard++ {
+		master := place(shard, ShardRoleMaster, nil)
+		if master == nil {
+			placement.Reason = fmt.Sprintf("no node has %s provisional RAM and a free shard for master shard %d", shardSize.String(), shard)
+			return placement
+		}
+		if replication && place(shard, ShardRoleReplica, master) == nil {
+			placement.Reason = fmt.Sprintf("no node other than %s has %s provisional RAM and a free shard for replica shard %d", master.Id, shardSize.String(), shard)
+			return placement
+		}
+	}
+
+	placement.Fits = true
+	return placement
+}
+
+// betterNode returns true if node is a better choice than best for a shard. A node in a different
+// rack to avoid is preferred, then the node with the most free RAM.
+func betterNode(node, best, avoid *NodeCapacity, free map[NodeID]RAMFloat) bool {
+	if avoid != nil && avoid.RackId != "" {
+		if otherRack := node.RackId != avoid.RackId; otherRack != (best.RackId != avoid.RackId) {
+			return otherRack
+		}
+	}
+	return free[node.Id] > free[best.Id]
+}
+
+func percentage(used, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return used * 100 / total
+}
+
+func (r *CapacityReport) JSON() (string, error) {
+	if out, err := json.Marshal(r); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (p *Placement) JSON() (string, error) {
+	if out, err := json.Marshal(p); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (n NodeCapacities) JSON() (string, error) {
+	if out, err := json.Marshal(n); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (n NodeCapacities) CSV(skipHeaders bool) (string, error) {
+	if skipHeaders {
+		return gocsv.MarshalStringWithoutHeaders(n)
+	} else {
+		return gocsv.MarshalString(n)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacity(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	report := info.Capacity()
+	assert.Len(t, report.Nodes, len(info.Nodes))
+	assert.Equal(t, 574, report.Shards)
+	assert.Equal(t, 3600, report.MaxShards)
+	assert.Equal(t, NodeID(11), report.MostConstrained)
+
+	// totals for large clusters don't fit in the per node types
+	large := &ClusterInfo{Key: "large"}
+	for n := 1; n <= 20; n++ {
+		large.Nodes = append(large.Nodes, &Node{Id: NodeID(n), ShardUsage: ShardInfo{InUse: 3000, Max: 4000}})
+	}
+	assert.Equal(t, 80000, large.Capacity().MaxShards)
+	assert.InDelta(t, 75.0, large.Capacity().ShardUtilisation, 0.001)
+
+	node := report.Nodes[0]
+	assert.Equal(t, NodeID(1), node.Id)
+	assert.InDelta(t, 72.58, float64(node.UsedRAM), 0.001)
+	assert.InDelta(t, 57.68, node.RAMUtilisation, 0.01)
+	assert.InDelta(t, 31.33, node.ShardUtilisation, 0.01)
+	assert.True(t, report.Nodes[len(report.Nodes)-1].Quorum)
+
+	placement := report.Fit(10, 2, true)
+	assert.True(t, placement.Fits)
+	if assert.Len(t, placement.Shards, 4) {
+		racks := map[NodeID]string{}
+		for _, n := range info.Nodes {
+			racks[n.Id] = n.RackId
+		}
+		for n := 0; n < 4; n += 2 {
+			master, replica := placement.Shards[n], placement.Shards[n+1]
+			assert.Equal(t, ShardRoleMaster, master.Role)
+			assert.Equal(t, ShardRoleReplica, replica.Role)
+			assert.InDelta(t, 5.0, float64(master.Size), 0.0001)
+			assert.NotEqual(t, racks[master.Node], racks[replica.Node])
+		}
+	}
+
+	placement = report.Fit(1000, 1, false)
+	assert.False(t, placement.Fits)
+	assert.NotEmpty(t, placement.Reason)
+
+	_, err = report.JSON()
+	assert.Nil(t, err)
+	_, err = report.Nodes.CSV(false)
+	assert.Nil(t, err)
+}
@@ -16,14 +16,18 @@ import (
 // Chunks is used to store the output of the base parser.
 type Chunks struct {
 	Intro     string
+	Cluster   []byte
 	Nodes     []byte
 	Databases []byte
 	Endpoints []byte
 	Shards    []byte
+	starts    map[int]int // line number in the input of the first line of each chunk
 }
 
 var marker = regexp.MustCompile(`^([A-Z ]+):$`)
 
+const timeStampFormat = "2006-01-02 15:04:05.000000-07:00"
+
 const (
 	ChunkNone = iota
 	ChunkCluster
@@ -44,15 +48,19 @@ var chunkMap = map[string]int{
 func (c *Chunks) Parse(input io.Reader) error {
 
 	current := make([]byte, 0)
+	c.starts = map[int]int{ChunkNone: 1}
 
 	where := ChunkNone
+	lineNum := 0
 	scanner := bufio.NewScanner(input)
 
 	for scanner.Scan() {
+		lineNum++
 		line := scanner.Bytes()
 		if newChunk := c.whichChunk(line); newChunk != ChunkNone {
 			c.putData(current, where)
 			where = newChunk
+			c.starts[where] = lineNum + 1
 			current = make([]byte, 0)
 		} else {
 			line := append(line, '\n')
@@ -74,6 +82,8 @@ func (c *Chunks) putData(data []byte, stage int) {
 		switch stage {
 		case ChunkNone:
 			c.Intro = c.Intro + "\n" + string(data) // Don't convert this
+		case ChunkCluster:
+			c.Cluster = data
 		case ChunkNodes:
 			c.Nodes = data
 		case ChunkDatabases:
@@ -89,13 +99,53 @@ func (c *Chunks) putData(data []byte, stage int) {
 // ExtractTimeStamp finds the timestamp at the start of the output and returns it as time.Time
 func (c *Chunks) ExtractTimeStamp() (time.Time, error) {
 
-	lines := strings.Split(c.Intro, "\n")
-	if len(lines) < 2 {
-		return time.Now(), fmt.Errorf("rlatool - timestamp not found in input")
-	} else {
-		return time.Parse("2006-01-02 03:04:05.000000-07:00", lines[1])
+	for _, line := range strings.Split(c.Intro, "\n") {
+		if ts, err := time.Parse(timeStampFormat, strings.TrimSpace(line)); err == nil {
+			return ts, nil
+		}
+	}
+
+	return time.Now(), fmt.Errorf("rlatool - timestamp not found in input")
+}
+
+// startLine returns the line number in the original input of the first line of a chunk
+// or zero if it isn't known.
+func (c *Chunks) startLine(chunk int) int {
+	return c.starts[chunk]
+}
+
+// chunkLine returns a line of a chunk given its line number in the original input or an
+// empty string if the line isn't in the chunk.
+func (c *Chunks) chunkLine(chunk, line int) string {
+	var data []byte
+	switch chunk {
+	case ChunkCluster:
+		data = c.Cluster
+	case ChunkNodes:
+		data = c.Nodes
+	case ChunkDatabases:
+		data = c.Databases
+	case ChunkEndpoints:
+		data = c.Endpoints
+	case ChunkShards:
+		data = c.Shards
 	}
 
+	lines := strings.Split(string(data), "\n")
+	if n := line - c.startLine(chunk); n >= 0 && n < len(lines) {
+		return lines[n]
+	}
+	return ""
+}
+
+// chunkName returns the section marker used in the rladmin output for a chunk
+func chunkName(chunk int) string {
+	for name, which := range chunkMap {
+		if which == chunk {
+			return name
+		}
+	}
+	return ""
 }
 
 // Get the id of the chunk we've encountered
@@ -0,0 +1,151 @@
+/*
+cluster.go provides a parser for the cluster status information in the rladmin output
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocarina/gocsv"
+)
+
+var (
+	clusterMasterLine   = regexp.MustCompile(`^(\S+)\.\s+Cluster master:\s+(\d+)\s+\(([^)]*)\)$`)
+	clusterHealthLine   = regexp.MustCompile(`^Cluster health:\s+([^,]+),\s+\[(.*)\]$`)
+	clusterFailuresLine = regexp.MustCompile(`^failures/minute - avg1 ([\d.]+), avg15 ([\d.]+), avg60 ([\d.]+)\.?$`)
+)
+
+// FailureRates holds the failures per minute averaged over 1, 15 and 60 minutes
+type FailureRates struct {
+	Avg1  float64 `json:"avg1" csv:"avg1"`
+	Avg15 float64 `json:"avg15" csv:"avg15"`
+	Avg60 float64 `json:"avg60" csv:"avg60"`
+}
+
+// ClusterStatus represents the CLUSTER section of the rladmin status output. MasterNode is
+// zero if the section has no cluster master line.
+type ClusterStatus struct {
+	Key           string       `json:"key" csv:"key"`
+	Status        string       `json:"status" csv:"status"`
+	MasterNode    NodeID       `json:"masterNode" csv:"masterNode"`
+	MasterAddress IP           `json:"masterAddress" csv:"masterAddress"`
+	Health        string       `json:"health" csv:"health"`
+	Failures      FailureRates `json:"failures" csv:"failures"`
+	TimeStamp     time.Time    `json:"timeStamp" csv:"timeStamp"`
+	parent        *ClusterInfo `csv:"-"`
+}
+
+// ParseCluster parses the CLUSTER section. If the section is not present, nil is returned
+// with no error.
+func (c *Chunks) ParseCluster(parent *ClusterInfo) (*ClusterStatus, error) {
+
+	if len(bytes.TrimSpace(c.Cluster)) == 0 {
+		return nil, nil
+	}
+
+	status := &ClusterStatus{
+		parent:    parent,
+		Key:       parent.Key,
+		TimeStamp: parent.TimeStamp,
+	}
+	haveRates := false
+
+	lineNum := c.startLine(ChunkCluster) - 1
+	scanner := bufio.NewScanner(bytes.NewReader(c.Cluster))
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		if matched := clusterMasterLine.FindStringSubmatch(line); matched != nil {
+			status.Status = matched[1]
+			master, err := ParseNodeID(matched[2])
+			if err != nil {
+				return nil, clusterError(lineNum, matched[2], err)
+			}
+			status.MasterNode = master
+			if err := status.MasterAddress.UnmarshalText([]byte(matched[3])); err != nil {
+				return nil, clusterError(lineNum, matched[3], err)
+			}
+		} else if matched := clusterHealthLine.FindStringSubmatch(line); matched != nil {
+			status.Health = matched[1]
+			// the bracketed values are the unrounded failure rates
+			if rates, err := parseFailureRates(strings.Split(matched[2], ",")); err == nil {
+				status.Failures = rates
+				haveRates = true
+			}
+		} else if matched := clusterFailuresLine.FindStringSubmatch(line); matched != nil && !haveRates {
+			rates, err := parseFailureRates(matched[1:])
+			if err != nil {
+				return nil, clusterError(lineNum, line, err)
+			}
+			status.Failures = rates
+		}
+	}
+
+	return status, scanner.Err()
+}
+
+func clusterError(line int, value string, err error) *ParseError {
+	return &ParseError{
+		Section: chunkName(ChunkCluster),
+		Line:    line,
+		Value:   value,
+		Err:     err,
+	}
+}
+
+func parseFailureRates(values []string) (FailureRates, error) {
+	rates := FailureRates{}
+
+	if len(values) != 3 {
+		return rates, fmt.Errorf("unable to split %s into parts for failure rates", strings.Join(values, ","))
+	}
+
+	for n, target := range []*float64{&rates.Avg1, &rates.Avg15, &rates.Avg60} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(values[n]), 64)
+		if err != nil {
+			return rates, fmt.Errorf(errorString, values[n], "failure rate", err)
+		}
+		*target = v
+	}
+
+	return rates, nil
+}
+
+// Master returns the node acting as cluster master, or nil if it can't be found.
+func (s *ClusterStatus) Master() *Node {
+	if s.parent == nil {
+		return nil
+	}
+	for _, node := range s.parent.Nodes {
+		if node.Id == s.MasterNode {
+			return node
+		}
+	}
+	return nil
+}
+
+func (s *ClusterStatus) JSON() (string, error) {
+	if out, err := json.Marshal(s); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (s *ClusterStatus) CSV(skipHeaders bool) (string, error) {
+	status := []*ClusterStatus{s}
+	if skipHeaders {
+		return gocsv.MarshalStringWithoutHeaders(status)
+	} else {
+		return gocsv.MarshalString(status)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/nic-gibson/go-bytesize"
@@ -20,13 +22,16 @@ type Serializer interface {
 
 // ClusterInfo represents all the data loaded from the rladmin status output
 type ClusterInfo struct {
-	Key       string    `json:"key"`
-	Unparsed  *Chunks   `json:"-"`
-	Databases Databases `json:"databases"`
-	Endpoints Endpoints `json:"endpoints"`
-	Shards    Shards    `json:"shards"`
-	Nodes     Nodes     `json:"nodes"`
-	TimeStamp time.Time `json:"timeStamp"`
+	Key        string         `json:"key"`
+	Unparsed   *Chunks        `json:"-"`
+	Cluster    *ClusterStatus `json:"cluster"`
+	Databases  Databases      `json:"databases"`
+	Endpoints  Endpoints      `json:"endpoints"`
+	Shards     Shards         `json:"shards"`
+	Nodes      Nodes          `json:"nodes"`
+	SourceNode NodeID         `json:"sourceNode"` // the node rladmin was run on
+	TimeStamp  time.Time      `json:"timeStamp"`
+	index      atomic.Pointer[clusterIndex]
 }
 
 type RAMFloat float64
@@ -41,6 +46,11 @@ func (g *RAMFloat) MarshalText() ([]byte, error) {
 	return []byte(fmt.Sprintf("%0.5f", *g)), nil
 }
 
+// Bytes returns the value converted from gigabytes to the nearest byte
+func (g RAMFloat) Bytes() float64 {
+	return math.Round(float64(g) * float64(bytesize.GB))
+}
+
 func toUint16(s string) (uint16, error) {
 	v, err := strconv.Atoi(s)
 	if err != nil {
@@ -52,6 +62,9 @@ func toUint16(s string) (uint16, error) {
 }
 
 func parseMemory(s string) (RAMFloat, error) {
+	if len(s) == 0 {
+		return 0, fmt.Errorf("rlatool - empty memory size")
+	}
 	invert := 1
 	if s[0] == '-' {
 		invert = -1
@@ -66,46 +79,82 @@ func parseMemory(s string) (RAMFloat, error) {
 
 }
 
+// NewClusterInfo parses rladmin status output and returns the cluster information
+// loaded from it. Parsing stops at the first error.
 func NewClusterInfo(key string, in io.Reader) (*ClusterInfo, error) {
+	info, _, err := NewClusterInfoWithOptions(key, in, ParseOptions{})
+	return info, err
+}
 
-	info := &ClusterInfo{}
+// NewClusterInfoWithOptions parses rladmin status output under the control of the options
+// given. In lenient mode, rows which can't be parsed are skipped and returned as issues.
+func NewClusterInfoWithOptions(key string, in io.Reader, opts ParseOptions) (*ClusterInfo, ParseIssues, error) {
 
 	chunks := &Chunks{}
-	err := chunks.Parse(in)
+	if err := chunks.Parse(in); err != nil {
+		return nil, nil, err
+	}
 
-	if err != nil {
-		return nil, err
-	} else {
-		info.Unparsed = chunks
+	return chunks.clusterInfo(key, opts)
+}
 
-	}
+// clusterInfo builds the cluster information from chunks which have already been parsed.
+func (c *Chunks) clusterInfo(key string, opts ParseOptions) (*ClusterInfo, ParseIssues, error) {
 
-	ts, err := chunks.ExtractTimeStamp()
+	var found ParseIssues
+
+	info := &ClusterInfo{Key: key, Unparsed: c}
+	issues := ParseIssues{}
+
+	ts, err := c.ExtractTimeStamp()
 	if err == nil {
 		info.TimeStamp = ts
 	}
 
-	info.Endpoints, err = chunks.ParseEndpoints(info)
+	info.Cluster, err = c.ParseCluster(info)
 	if err != nil {
-		return nil, err
+		if !opts.Lenient {
+			return nil, nil, err
+		}
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			parseErr = &ParseError{Section: chunkName(ChunkCluster), Line: c.startLine(ChunkCluster), Err: err}
+		}
+		issues = append(issues, ParseIssue{
+			Section: parseErr.Section,
+			Line:    parseErr.Line,
+			Raw:     c.chunkLine(ChunkCluster, parseErr.Line),
+			Err:     parseErr,
+		})
 	}
 
-	info.Databases, err = chunks.ParseDatabases(info)
+	info.Endpoints, found, err = c.parseEndpoints(info, opts.Lenient)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	issues = append(issues, found...)
 
-	info.Shards, err = chunks.ParseShards(info)
+	info.Databases, found, err = c.parseDatabases(info, opts.Lenient)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	issues = append(issues, found...)
 
-	info.Nodes, err = chunks.ParseNodes(info)
+	info.Shards, found, err = c.parseShards(info, opts.Lenient)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	issues = append(issues, found...)
 
-	return info, nil
+	info.Nodes, found, err = c.parseNodes(info, opts.Lenient)
+	if err != nil {
+		return nil, nil, err
+	}
+	issues = append(issues, found...)
+
+	info.Reindex()
+
+	return info, issues, nil
 }
 
 func (c *ClusterInfo) DatabasesWithNodes() DatabasesWithNodes {
@@ -125,7 +174,10 @@ func (c *ClusterInfo) CSV(skipHeaders bool) (map[string]string, error) {
 	var err error
 	csvinfo := map[string]string{}
 
-	csvinfo["nodes"], err = c.Databases.CSV(skipHeaders)
+	csvinfo["databases"], err = c.Databases.CSV(skipHeaders)
+	if err == nil && c.Cluster != nil {
+		csvinfo["cluster"], err = c.Cluster.CSV(skipHeaders)
+	}
 	if err == nil {
 		csvinfo["endpoints"], err = c.Endpoints.CSV(skipHeaders)
 		if err == nil {
@@ -6,6 +6,7 @@ package clusterinfo
 import (
 	"bytes"
 	_ "embed"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,7 +42,10 @@ func TestNodes(t *testing.T) {
 		nodes, err := chunks.ParseNodes(info)
 		if assert.Nil(t, err) {
 			assert.Len(t, nodes, 13)
-			assert.Equal(t, nodes[0].Id, "node:1")
+			assert.Equal(t, NodeID(1), nodes[0].Id)
+			assert.True(t, nodes[0].IsLocal)
+			assert.False(t, nodes[1].IsLocal)
+			assert.Equal(t, NodeID(1), info.SourceNode)
 			assert.Equal(t, nodes[0].Masters+nodes[0].Replicas, nodes[0].ShardUsage.InUse)
 			assert.Equal(t, nodes[0].ShardUsage.InUse, uint16(94))
 			assert.LessOrEqual(t, nodes[0].RedisRAM.Free, 53.24)
@@ -61,7 +65,7 @@ func TestDatabases(t *testing.T) {
 		dbs, err := chunks.ParseDatabases(info)
 		if assert.Nil(t, err) {
 			assert.Len(t, dbs, 143)
-			assert.Equal(t, dbs[0].Id, "db:10567021")
+			assert.Equal(t, DBID(10567021), dbs[0].Id)
 			assert.Equal(t, dbs[0].Endpoint, DBEndPoints([]string{
 				"redis-17798.c99999.us-central1-mz.gcp.cloud.rlrcp.com:17798",
 				"redis-17798.c99999.us-central1-mz.gcp.redns.redis-cloud.com:17798",
@@ -98,7 +102,7 @@ func TestEndpoints(t *testing.T) {
 		if assert.Nil(t, err) {
 			assert.Len(t, eps, 144)
 			assert.Equal(t, eps[1].Name, "cambodia-00")
-			assert.Equal(t, eps[1].Role, "single")
+			assert.Equal(t, eps[1].Role, PolicySingle)
 		}
 	}
 }
@@ -106,10 +110,124 @@ func TestEndpoints(t *testing.T) {
 func TestRSOutput(t *testing.T) {
 
 	buffer := bytes.NewReader(rsOutput)
-	info, err := NewClusterInfo(buffer)
+	info, err := NewClusterInfo("test", buffer)
 	assert.Nil(t, err)
 
 	ts, _ := time.Parse("2006-01-02 15:04:05.000000-07:00", "2024-06-20 14:29:15.909661+02:00")
 	assert.Equal(t, info.TimeStamp, ts)
 
 }
+
+func TestCluster(t *testing.T) {
+	var chunks *Chunks
+	var info = &ClusterInfo{}
+
+	buffer := bytes.NewReader(rsOutput)
+	chunks = &Chunks{}
+	err := chunks.Parse(buffer)
+	if assert.Nil(t, err) {
+		status, err := chunks.ParseCluster(info)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "OK", status.Status)
+			assert.Equal(t, NodeID(1), status.MasterNode)
+			assert.Equal(t, "10.166.204.139", status.MasterAddress.String())
+			assert.Equal(t, "OK", status.Health)
+			assert.Equal(t, FailureRates{Avg1: 88, Avg15: 84.4, Avg60: 85.06666666666666}, status.Failures)
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	corrupted := bytes.Replace(rladmin, []byte("10.34GB "), []byte("10.34XB "), 1)
+
+	_, err := NewClusterInfo("test", bytes.NewReader(corrupted))
+	assert.NotNil(t, err)
+
+	info, issues, err := NewClusterInfoWithOptions("test", bytes.NewReader(corrupted), ParseOptions{Lenient: true})
+	if assert.Nil(t, err) && assert.Len(t, issues, 1) {
+		assert.Len(t, info.Shards, 573)
+		assert.Len(t, info.Nodes, 13)
+		assert.Equal(t, "SHARDS", issues[0].Section)
+		assert.Equal(t, 323, issues[0].Line)
+		assert.Equal(t, "USED_MEMORY", issues[0].Column)
+		assert.Contains(t, issues[0].Raw, "redis:6 ")
+	}
+
+	corrupted = bytes.Replace(rladmin, []byte("(10.10.21.11)"), []byte("(10.10.21.X)"), 1)
+	info, issues, err = NewClusterInfoWithOptions("test", bytes.NewReader(corrupted), ParseOptions{Lenient: true})
+	if assert.Nil(t, err) && assert.Len(t, issues, 1) {
+		assert.Nil(t, info.Cluster)
+		assert.Equal(t, "CLUSTER", issues[0].Section)
+		assert.Equal(t, 7, issues[0].Line)
+		assert.Equal(t, "OK. Cluster master: 3 (10.10.21.X)", issues[0].Raw)
+	}
+
+	assert.NotPanics(t, func() { _ = ParseIssue{}.Error() })
+}
+
+func TestParseError(t *testing.T) {
+	var parseErr *ParseError
+
+	corrupted := bytes.Replace(rladmin, []byte("10.10.21.5 "), []byte("10.10.21.X "), 1)
+	_, err := NewClusterInfo("test", bytes.NewReader(corrupted))
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, "CLUSTER NODES", parseErr.Section)
+		assert.Equal(t, 14, parseErr.Line)
+		assert.Equal(t, "ADDRESS", parseErr.Column)
+		assert.Equal(t, "10.10.21.X", parseErr.Value)
+	}
+
+	// columns are found by rune, not byte, when the header isn't ASCII
+	type row struct {
+		Name string   `column:"NÄME"`
+		Size RAMFloat `column:"GRÖSSE"`
+	}
+	column, value := failedColumn[row]("NÄME  GRÖSSE  ", "café  1.5XB   ")
+	assert.Equal(t, "GRÖSSE", column)
+	assert.Equal(t, "1.5XB", value)
+}
+
+func TestMetrics(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+
+	text, err := info.Prometheus()
+	if assert.Nil(t, err) {
+		assert.Contains(t, text, "# TYPE rladmin_node_info gauge\n")
+		assert.Contains(t, text, `rladmin_shard_used_memory_bytes{key="test",db="db:10",shard="redis:1",node="node:12"} 1717986918`)
+		assert.NotContains(t, text, "# EOF")
+	}
+
+	text, err = info.OpenMetrics()
+	if assert.Nil(t, err) {
+		assert.Contains(t, text, "# TYPE rladmin_node info\n")
+		assert.Contains(t, text, `rladmin_cluster_info{key="test",master_node="node:1",status="OK",health="OK"} 1`)
+		assert.True(t, strings.HasSuffix(text, "# EOF\n"))
+	}
+}
+
+func TestSlots(t *testing.T) {
+	var ranges SlotRanges
+	assert.Nil(t, ranges.UnmarshalText([]byte("0-99,200,300-16383")))
+	assert.Equal(t, SlotRanges{{0, 99}, {200, 200}, {300, 16383}}, ranges)
+	assert.Equal(t, "0-99,200,300-16383", ranges.String())
+	assert.Equal(t, 100+1+16084, ranges.Count())
+	assert.NotNil(t, ranges.UnmarshalText([]byte("0-16384")))
+
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+	assert.Empty(t, info.ValidateSlots())
+
+	broken := bytes.Replace(rsOutput, []byte("redis:41 node:30 master 0-545   "), []byte("redis:41 node:30 master 0-540   "), 1)
+	broken = bytes.Replace(broken, []byte("redis:43 node:6  master 1092-1638"), []byte("redis:43 node:6  master 1000-1638"), 1)
+	info, err = NewClusterInfo("test", bytes.NewReader(broken))
+	assert.Nil(t, err)
+
+	report := info.ValidateSlots()
+	if assert.Len(t, report, 1) {
+		assert.Equal(t, SlotRanges{{541, 545}}, report[0].Gaps)
+		assert.Equal(t, SlotRanges{{1000, 1091}}, report[0].Overlaps)
+		assert.Equal(t, []ShardID{1, 4}, report[0].Unmatched)
+		assert.Equal(t, []ShardID{41, 43}, report[0].Unreplicated)
+	}
+}
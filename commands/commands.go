@@ -0,0 +1,204 @@
+/*
+commands.go builds rladmin command lines from actions on the entities in cluster information
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goslogan/clusterinfo"
+)
+
+// ErrUnknownId is returned when an action refers to an id not present in the cluster information
+var ErrUnknownId = errors.New("rlatool - id not found in cluster information")
+
+// ErrInvalidAction is returned when an action can't be applied to the cluster as it is
+var ErrInvalidAction = errors.New("rlatool - invalid action")
+
+// EndpointPolicy is a proxy policy for an endpoint
+type EndpointPolicy = clusterinfo.ProxyPolicy
+
+const (
+	PolicySingle          = clusterinfo.PolicySingle
+	PolicyAllMasterShards = clusterinfo.PolicyAllMasterShards
+	PolicyAllNodes        = clusterinfo.PolicyAllNodes
+)
+
+// Action is a change to the cluster which can be expressed as an rladmin command
+type Action interface {
+	// Validate checks the action against the cluster information
+	Validate(info *clusterinfo.ClusterInfo) error
+	// Command returns the rladmin command line for the action
+	Command() string
+}
+
+// FailoverShard fails over a master shard to its replica
+type FailoverShard struct {
+	Shard     *clusterinfo.Shard
+	Immediate bool
+}
+
+// MigrateShard moves a shard to another node
+type MigrateShard struct {
+	Shard         *clusterinfo.Shard
+	Target        *clusterinfo.Node
+	PreserveRoles bool
+}
+
+// BindEndpointPolicy sets the proxy policy of an endpoint
+type BindEndpointPolicy struct {
+	Endpoint *clusterinfo.Endpoint
+	Policy   EndpointPolicy
+}
+
+// Builder collects validated actions for a cluster
+type Builder struct {
+	info    *clusterinfo.ClusterInfo
+	actions []Action
+}
+
+// NewBuilder returns a builder for actions on the cluster described by info
+func NewBuilder(info *clusterinfo.ClusterInfo) *Builder {
+	return &Builder{info: info, actions: []Action{}}
+}
+
+// Add validates and adds actions. If an action is invalid, an error is returned and it
+// and any following actions are not added.
+func (b *Builder) Add(actions ...Action) error {
+	for _, action := range actions {
+		if err := action.Validate(b.info); err != nil {
+			return err
+		}
+		b.actions = append(b.actions, action)
+	}
+	return nil
+}
+
+// Commands returns the command line for each action in the order they were added
+func (b *Builder) Commands() []string {
+	commands := make([]string, len(b.actions))
+	for n, action := range b.actions {
+		commands[n] = action.Command()
+	}
+	return commands
+}
+
+// Script returns the commands one per line
+func (b *Builder) Script() string {
+	if len(b.actions) == 0 {
+		return ""
+	}
+	return strings.Join(b.Commands(), "\n") + "\n"
+}
+
+func (a *FailoverShard) Validate(info *clusterinfo.ClusterInfo) error {
+	shard, err := findShard(info, a.Shard)
+	if err != nil {
+		return err
+	}
+
+	if shard.Role != clusterinfo.ShardRoleMaster {
+		return fmt.Errorf("%w: shard '%s' is not a master", ErrInvalidAction, shard.Id)
+	}
+	replica, err := partner(shard)
+	if err != nil {
+		return err
+	}
+	if replica == nil {
+		return fmt.Errorf("%w: shard '%s' has no replica", ErrInvalidAction, shard.Id)
+	}
+	return nil
+}
+
+func (a *FailoverShard) Command() string {
+	command := fmt.Sprintf("rladmin failover db %s shard %d", a.Shard.DBId, a.Shard.Id)
+	if a.Immediate {
+		command += " immediate"
+	}
+	return command
+}
+
+func (a *MigrateShard) Validate(info *clusterinfo.ClusterInfo) error {
+	shard, err := findShard(info, a.Shard)
+	if err != nil {
+		return err
+	}
+	if a.Target == nil {
+		return fmt.Errorf("%w: no target node given", ErrUnknownId)
+	}
+	target := info.Node(a.Target.Id)
+	if target == nil {
+		return fmt.Errorf("%w: node '%s'", ErrUnknownId, a.Target.Id)
+	}
+	if shard.NodeId == target.Id {
+		return fmt.Errorf("%w: shard '%s' is already on %s", ErrInvalidAction, shard.Id, target.Id)
+	}
+	if target.ShardUsage.InUse >= target.ShardUsage.Max {
+		return fmt.Errorf("%w: %s has no free shards", ErrInvalidAction, target.Id)
+	}
+	other, err := partner(shard)
+	if err != nil {
+		return err
+	}
+	if other != nil && other.NodeId == target.Id {
+		return fmt.Errorf("%w: shard '%s' and its pair '%s' would both be on %s", ErrInvalidAction, shard.Id, other.Id, target.Id)
+	}
+	return nil
+}
+
+func (a *MigrateShard) Command() string {
+	return clusterinfo.MigrateShardCommand(a.Shard.Id, a.Target.Id, a.PreserveRoles)
+}
+
+func (a *BindEndpointPolicy) Validate(info *clusterinfo.ClusterInfo) error {
+	if a.Endpoint == nil {
+		return fmt.Errorf("%w: no endpoint given", ErrUnknownId)
+	}
+	if len(info.Endpoint(a.Endpoint.Id)) == 0 {
+		return fmt.Errorf("%w: endpoint '%s'", ErrUnknownId, a.Endpoint.Id)
+	}
+
+	if a.Policy.IsUnknown() {
+		return fmt.Errorf("%w: unknown endpoint policy '%s'", ErrInvalidAction, a.Policy)
+	}
+	return nil
+}
+
+func (a *BindEndpointPolicy) Command() string {
+	return fmt.Sprintf("rladmin bind db %s endpoint %d:%d policy %s",
+		a.Endpoint.DBId, a.Endpoint.Id.DB, a.Endpoint.Id.Number, a.Policy)
+}
+
+// findShard returns the shard in info with the same id as shard
+func findShard(info *clusterinfo.ClusterInfo, shard *clusterinfo.Shard) (*clusterinfo.Shard, error) {
+	if shard == nil {
+		return nil, fmt.Errorf("%w: no shard given", ErrUnknownId)
+	}
+	if found := info.Shard(shard.Id); found != nil {
+		return found, nil
+	}
+	return nil, fmt.Errorf("%w: shard '%s'", ErrUnknownId, shard.Id)
+}
+
+// partner returns the replica of a master shard or the master of a replica. It returns nil if
+// the shard isn't part of a pair.
+func partner(shard *clusterinfo.Shard) (*clusterinfo.Shard, error) {
+	db := shard.Database()
+	if db == nil {
+		return nil, fmt.Errorf("%w: database '%s' of shard '%s'", ErrUnknownId, shard.DBId, shard.Id)
+	}
+	for _, pair := range db.Shards().Pairs() {
+		if pair.Replica == nil {
+			continue
+		}
+		if pair.Master == shard {
+			return pair.Replica, nil
+		} else if pair.Replica == shard {
+			return pair.Master, nil
+		}
+	}
+	return nil, nil
+}
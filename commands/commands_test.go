@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package commands
+
+import (
+	"os"
+	"testing"
+
+	"github.com/goslogan/clusterinfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadInfo(t *testing.T) *clusterinfo.ClusterInfo {
+	in, err := os.Open("../testdata/node_1.rladmin")
+	assert.Nil(t, err)
+	defer in.Close()
+
+	info, err := clusterinfo.NewClusterInfo("test", in)
+	assert.Nil(t, err)
+	return info
+}
+
+func TestBuilder(t *testing.T) {
+	info := loadInfo(t)
+	builder := NewBuilder(info)
+
+	err := builder.Add(
+		&FailoverShard{Shard: info.Shard(5)},
+		&MigrateShard{Shard: info.Shard(6), Target: info.Node(9)},
+		&MigrateShard{Shard: info.Shard(5), Target: info.Node(2), PreserveRoles: true},
+		&BindEndpointPolicy{Endpoint: info.LookupEndpoint("endpoint:10567021:1")[0], Policy: PolicyAllMasterShards},
+	)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"rladmin failover db db:10567021 shard 5",
+		"rladmin migrate shard 6 target_node 9",
+		"rladmin migrate shard 5 preserve_roles target_node 2",
+		"rladmin bind db db:10567021 endpoint 10567021:1 policy all-master-shards",
+	}, builder.Commands())
+	assert.Contains(t, builder.Script(), "shard 5\nrladmin migrate")
+
+	// rebalance plans use the same command lines
+	plan := info.PlanRebalance(5)
+	assert.NotEmpty(t, plan.Steps)
+	for _, step := range plan.Steps {
+		migrate := &MigrateShard{
+			Shard:         info.Shard(step.Shard),
+			Target:        info.Node(step.To),
+			PreserveRoles: step.Role == clusterinfo.ShardRoleMaster,
+		}
+		assert.Equal(t, migrate.Command(), step.Command)
+	}
+}
+
+func TestBuilderInvalid(t *testing.T) {
+	info := loadInfo(t)
+	builder := NewBuilder(info)
+
+	err := builder.Add(&FailoverShard{Shard: &clusterinfo.Shard{Id: 99999}})
+	assert.ErrorIs(t, err, ErrUnknownId)
+
+	err = builder.Add(&MigrateShard{Shard: info.Shard(5), Target: &clusterinfo.Node{Id: 99}})
+	assert.ErrorIs(t, err, ErrUnknownId)
+
+	err = builder.Add(&BindEndpointPolicy{Endpoint: &clusterinfo.Endpoint{Id: clusterinfo.EndpointID{DB: 1, Number: 1}}, Policy: PolicySingle})
+	assert.ErrorIs(t, err, ErrUnknownId)
+
+	err = builder.Add(&FailoverShard{Shard: info.Shard(6)})
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	err = builder.Add(&MigrateShard{Shard: info.Shard(5), Target: info.Node(7)})
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	err = builder.Add(&MigrateShard{Shard: info.Shard(5), Target: info.Node(14)})
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	err = builder.Add(&BindEndpointPolicy{Endpoint: info.LookupEndpoint("endpoint:10567021:1")[0], Policy: "sometimes"})
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	// a master and its replica can't share a node
+	master, replica := info.Shard(5), info.Shard(6)
+	err = builder.Add(&MigrateShard{Shard: master, Target: info.Node(replica.NodeId)})
+	assert.ErrorIs(t, err, ErrInvalidAction)
+	err = builder.Add(&MigrateShard{Shard: replica, Target: info.Node(master.NodeId)})
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	// shards of a database which isn't in the cluster information
+	orphaned := loadInfo(t)
+	orphaned.Shard(5).DBId = 1
+	err = NewBuilder(orphaned).Add(&FailoverShard{Shard: orphaned.Shard(5)})
+	assert.ErrorIs(t, err, ErrUnknownId)
+
+	assert.Empty(t, builder.Commands())
+	assert.Equal(t, "", builder.Script())
+}
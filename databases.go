@@ -5,13 +5,12 @@ Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
 package clusterinfo
 
 import (
-	"bytes"
 	"encoding/json"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gocarina/gocsv"
-	"github.com/goslogan/fw"
 )
 
 type DBEndPoints []string
@@ -20,26 +19,26 @@ type DBShards struct {
 	Masters  uint16
 	Replicas uint16
 }
-type DBNodes map[string]*DBShards
+type DBNodes map[NodeID]*DBShards
 
 type Database struct {
-	Key               string       `columh:"-" json:"key" csv:"key"`
-	Id                string       `column:"DB:ID" json:"id" csv:"id"`
-	Name              string       `column:"NAME" json:"name" csv:"name"`
-	Type              string       `column:"TYPE" json:"type" csv:"type"`
-	Status            string       `column:"STATUS" json:"status" csv:"status"`
-	MasterShards      uint16       `column:"SHARDS" json:"shards" csv:"shards"`
-	Placement         string       `column:"PLACEMENT" json:"placement" csv:"placement"`
-	Replication       string       `column:"REPLICATION" json:"replication" csv:"replication"`
-	Persistence       string       `column:"PERSISTENCE" json:"persistence" csv:"persistence"`
-	Endpoint          DBEndPoints  `column:"ENDPOINT" json:"endpoints" csv:"endpoints"`
-	ExecState         string       `column:"EXEC_STATE" json:"execState" csv:"execState"`
-	ExecStateMachine  string       `column:"EXEC_STATE_MACHINE" json:"execStateMachine" csv:"execStateMachine"`
-	BackupProgress    string       `column:"BACKUP_PROGRESS" json:"backupProgress" csv:"backupProgress"`
-	MissingBackupTime string       `column:"MISSING_BACKUP_TIME" json:"missingBackupTime" csv:"missingBackupTime"`
-	RedisVersion      string       `column:"REDIS_VERSION" json:"redisVersion" csv:"redisVersion"`
-	TimeStamp         time.Time    `json:"timeStamp" csv:"timeStamp" column:"-"`
-	parent            *ClusterInfo `json:"-" csv:"-"`
+	Key               string          `columh:"-" json:"key" csv:"key"`
+	Id                DBID            `column:"DB:ID" json:"id" csv:"id"`
+	Name              string          `column:"NAME" json:"name" csv:"name"`
+	Type              string          `column:"TYPE" json:"type" csv:"type"`
+	Status            DatabaseStatus  `column:"STATUS" json:"status" csv:"status"`
+	MasterShards      uint16          `column:"SHARDS" json:"shards" csv:"shards"`
+	Placement         ShardPlacement  `column:"PLACEMENT" json:"placement" csv:"placement"`
+	Replication       ReplicationMode `column:"REPLICATION" json:"replication" csv:"replication"`
+	Persistence       PersistenceMode `column:"PERSISTENCE" json:"persistence" csv:"persistence"`
+	Endpoint          DBEndPoints     `column:"ENDPOINT" json:"endpoints" csv:"endpoints"`
+	ExecState         ExecState       `column:"EXEC_STATE" json:"execState" csv:"execState"`
+	ExecStateMachine  string          `column:"EXEC_STATE_MACHINE" json:"execStateMachine" csv:"execStateMachine"`
+	BackupProgress    string          `column:"BACKUP_PROGRESS" json:"backupProgress" csv:"backupProgress"`
+	MissingBackupTime string          `column:"MISSING_BACKUP_TIME" json:"missingBackupTime" csv:"missingBackupTime"`
+	RedisVersion      string          `column:"REDIS_VERSION" json:"redisVersion" csv:"redisVersion"`
+	TimeStamp         time.Time       `json:"timeStamp" csv:"timeStamp" column:"-"`
+	parent            *ClusterInfo    `json:"-" csv:"-"`
 }
 
 type DatabaseWithNodes struct {
@@ -51,15 +50,15 @@ type Databases []*Database
 type DatabasesWithNodes []*DatabaseWithNodes
 
 func (c *Chunks) ParseDatabases(parent *ClusterInfo) (Databases, error) {
+	databases, _, err := c.parseDatabases(parent, false)
+	return databases, err
+}
 
-	databases := []*Database{}
-	decoder := fw.NewDecoder(bytes.NewReader(c.Databases))
-	decoder.IgnoreEmptyRecords = true
-
-	err := decoder.Decode(&databases)
+func (c *Chunks) parseDatabases(parent *ClusterInfo, lenient bool) (Databases, ParseIssues, error) {
 
+	databases, issues, err := decodeChunk[Database](c, ChunkDatabases, c.Databases, lenient)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, db := range databases {
 		db.parent = parent
@@ -67,7 +66,7 @@ func (c *Chunks) ParseDatabases(parent *ClusterInfo) (Databases, error) {
 		db.TimeStamp = parent.TimeStamp
 	}
 
-	return databases, nil
+	return databases, issues, nil
 }
 
 // JSON returns the database struct marsalled to JSON
@@ -79,14 +78,16 @@ func (db *Database) JSON() (string, error) {
 	}
 }
 
-// OnNode returns the number of shards on the given node for a database.
-func (db *Database) OnNode(id string) DBShards {
+// OnNode returns the number of shards on the given node for a database. Shards
+// with an unknown role are not counted.
+func (db *Database) OnNode(id NodeID) DBShards {
 	var masters, replicas uint16
-	for _, shard := range db.parent.Shards.ForDB(db.Id) {
-		if shard.Node == id {
-			if shard.Role == "master" {
+	for _, shard := range db.Shards() {
+		if shard.NodeId == id {
+			switch shard.Role {
+			case ShardRoleMaster:
 				masters++
-			} else {
+			case ShardRoleReplica:
 				replicas++
 			}
 		}
@@ -96,24 +97,6 @@ func (db *Database) OnNode(id string) DBShards {
 }
 
 func (d *Database) withNodes() *DatabaseWithNodes {
-	nodes := DBNodes{}
-
-	for _, node := range d.parent.Nodes {
-		nodes[node.Id] = &DBShards{}
-	}
-
-	for _, shard := range d.parent.Shards {
-		if shard.DBId == d.Id {
-			shardCount := nodes[shard.Node]
-
-			if shard.Role == "master" {
-				shardCount.Masters++
-			} else {
-				shardCount.Replicas++
-			}
-		}
-	}
-
 	return &DatabaseWithNodes{
 		Database: *d,
 		Nodes:    d.getNodes(),
@@ -123,13 +106,7 @@ func (d *Database) withNodes() *DatabaseWithNodes {
 // ShardCount returns the total number of shards by
 // counting them.
 func (d *Database) ShardCount() uint16 {
-	shards := uint16(0)
-	for _, v := range d.getNodes() {
-		shards += v.Masters
-		shards += v.Replicas
-	}
-
-	return shards
+	return uint16(len(d.Shards()))
 }
 
 func (d *Database) getNodes() DBNodes {
@@ -139,15 +116,19 @@ func (d *Database) getNodes() DBNodes {
 		nodes[node.Id] = &DBShards{}
 	}
 
-	for _, shard := range d.parent.Shards {
-		if shard.DBId == d.Id {
-			shardCount := nodes[shard.Node]
+	for _, shard := range d.Shards() {
+		shardCount, ok := nodes[shard.NodeId]
+		if !ok {
+			// the node may be missing if it couldn't be parsed
+			shardCount = &DBShards{}
+			nodes[shard.NodeId] = shardCount
+		}
 
-			if shard.Role == "master" {
-				shardCount.Masters++
-			} else {
-				shardCount.Replicas++
-			}
+		switch shard.Role {
+		case ShardRoleMaster:
+			shardCount.Masters++
+		case ShardRoleReplica:
+			shardCount.Replicas++
 		}
 	}
 
@@ -205,13 +186,18 @@ func (e *DBEndPoints) MarshalCSV() (string, error) {
 
 func (n *DBNodes) MarshalCSV() (string, error) {
 
-	keys := []string{}
-
-	for k, v := range *n {
+	ids := []NodeID{}
+	for id, v := range *n {
 		if v.Masters+v.Replicas > 0 {
-			keys = append(keys, k)
+			ids = append(ids, id)
 		}
 	}
+	slices.Sort(ids)
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
 
 	return strings.Join(keys, "/"), nil
 }
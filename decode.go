@@ -0,0 +1,145 @@
+/*
+decode.go provides the row by row decoder shared by the section parsers
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goslogan/fw"
+)
+
+// ParseOptions controls the behaviour of NewClusterInfoWithOptions
+type ParseOptions struct {
+	// Lenient causes rows which can't be decoded to be skipped and
+	// reported as issues rather than aborting the parse.
+	Lenient bool
+}
+
+// ParseIssue describes a row which was skipped during a lenient parse
+type ParseIssue struct {
+	Section string `json:"section"`
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Raw     string `json:"raw"`
+	Err     error  `json:"-"`
+}
+
+type ParseIssues []ParseIssue
+
+// ParseError is returned when a value in the rladmin output can't be parsed. It
+// identifies the section, the line in the original input and, where possible, the
+// column and cell at fault.
+type ParseError struct {
+	Section string `json:"section"`
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Err     error  `json:"-"`
+}
+
+// same expression as used by fw to split the header line into columns
+var headerColumn = regexp.MustCompile(`.+?(?: +|$)`)
+
+func (i ParseIssue) Error() string {
+	if i.Err == nil {
+		return fmt.Sprintf("%s line %d: unable to parse '%s'", i.Section, i.Line, i.Raw)
+	}
+	return i.Err.Error()
+}
+
+func (i ParseIssue) Unwrap() error {
+	return i.Err
+}
+
+func (e *ParseError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("%s line %d, column %s ('%s'): %v", e.Section, e.Line, e.Column, e.Value, e.Err)
+	} else {
+		return fmt.Sprintf("%s line %d: %v", e.Section, e.Line, e.Err)
+	}
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// decodeChunk decodes the fixed width data for a chunk one row at a time. If lenient
+// is false, the first error is returned. Otherwise, failing rows are recorded as issues
+// and decoding continues.
+func decodeChunk[T any](c *Chunks, chunk int, data []byte, lenient bool) ([]*T, ParseIssues, error) {
+	items := []*T{}
+	issues := ParseIssues{}
+
+	lines := strings.Split(string(data), "\n")
+	decoder := fw.NewDecoder(bytes.NewReader(data))
+	decoder.IgnoreEmptyRecords = true
+
+	// the first line is the header so rows start at the second
+	for n := 1; n < len(lines); n++ {
+		if len(lines[n]) == 0 {
+			continue
+		}
+
+		item := new(T)
+		err := decoder.Decode(item)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			column, value := failedColumn[T](lines[0], lines[n])
+			parseErr := &ParseError{
+				Section: chunkName(chunk),
+				Line:    c.startLine(chunk) + n,
+				Column:  column,
+				Value:   value,
+				Err:     err,
+			}
+			if !lenient {
+				return nil, nil, parseErr
+			}
+			issues = append(issues, ParseIssue{
+				Section: parseErr.Section,
+				Line:    parseErr.Line,
+				Column:  parseErr.Column,
+				Raw:     lines[n],
+				Err:     parseErr,
+			})
+		} else {
+			items = append(items, item)
+		}
+	}
+
+	return items, issues, nil
+}
+
+// failedColumn works out which column of a row can't be decoded by decoding
+// each column in turn. It returns the column header and the trimmed cell or
+// empty strings if no single column fails.
+func failedColumn[T any](header, line string) (string, string) {
+	cells := []rune(line)
+	for _, index := range headerColumn.FindAllStringIndex(header, -1) {
+		// columns are found by byte offset in the header but cells are sliced by rune
+		name := strings.TrimSpace(header[index[0]:index[1]])
+		from := utf8.RuneCountInString(header[:index[0]])
+		to := from + utf8.RuneCountInString(header[index[0]:index[1]])
+		if to > len(cells) {
+			break
+		}
+
+		decoder := fw.NewDecoder(strings.NewReader(line))
+		decoder.SetHeaders(map[string][]int{name: {from, to}})
+		decoder.SkipLengthCheck = true
+
+		if err := decoder.Decode(new(T)); err != nil && err != io.EOF {
+			return name, strings.TrimSpace(string(cells[from:to]))
+		}
+	}
+
+	return "", ""
+}
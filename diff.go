@@ -0,0 +1,269 @@
+/*
+diff.go compares two sets of cluster information, typically taken before and after maintenance
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Change records a value which differs between two snapshots of the same entity
+type Change struct {
+	Id   string `json:"id"`
+	DBId DBID   `json:"dbId,omitempty"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ReshardChange records a change in the number of master shards for a database
+type ReshardChange struct {
+	Id   DBID   `json:"id"`
+	Name string `json:"name"`
+	From uint16 `json:"from"`
+	To   uint16 `json:"to"`
+}
+
+// MemoryDelta records a change in memory used by a shard
+type MemoryDelta struct {
+	Id    ShardID  `json:"id"`
+	DBId  DBID     `json:"dbId"`
+	From  RAMFloat `json:"from"`
+	To    RAMFloat `json:"to"`
+	Delta RAMFloat `json:"delta"`
+}
+
+// ClusterDiff is the set of changes between two ClusterInfo values
+type ClusterDiff struct {
+	From               time.Time       `json:"from"`
+	To                 time.Time       `json:"to"`
+	NodesAdded         []NodeID        `json:"nodesAdded"`
+	NodesRemoved       []NodeID        `json:"nodesRemoved"`
+	NodeStatus         []Change        `json:"nodeStatus"`
+	DatabasesCreated   []DBID          `json:"databasesCreated"`
+	DatabasesDeleted   []DBID          `json:"databasesDeleted"`
+	DatabasesResharded []ReshardChange `json:"databasesResharded"`
+	ShardsAdded        []ShardID       `json:"shardsAdded"`
+	ShardsRemoved      []ShardID       `json:"shardsRemoved"`
+	ShardsMoved        []Change        `json:"shardsMoved"`
+	ShardRoles         []Change        `json:"shardRoles"`
+	EndpointsMoved     []Change        `json:"endpointsMoved"`
+	Memory             []MemoryDelta   `json:"memory"`
+}
+
+// Diff compares two sets of cluster information and returns the changes
+// needed to get from a to b.
+func Diff(a, b *ClusterInfo) *ClusterDiff {
+	d := &ClusterDiff{
+		From:               a.TimeStamp,
+		To:                 b.TimeStamp,
+		NodesAdded:         []NodeID{},
+		NodesRemoved:       []NodeID{},
+		NodeStatus:         []Change{},
+		DatabasesCreated:   []DBID{},
+		DatabasesDeleted:   []DBID{},
+		DatabasesResharded: []ReshardChange{},
+		ShardsAdded:        []ShardID{},
+		ShardsRemoved:      []ShardID{},
+		ShardsMoved:        []Change{},
+		ShardRoles:         []Change{},
+		EndpointsMoved:     []Change{},
+		Memory:             []MemoryDelta{},
+	}
+
+	d.diffNodes(a.Nodes, b.Nodes)
+	d.diffDatabases(a.Databases, b.Databases)
+	d.diffShards(a.Shards, b.Shards)
+	d.diffEndpoints(a.Endpoints, b.Endpoints)
+
+	return d
+}
+
+func (d *ClusterDiff) diffNodes(a, b Nodes) {
+	before := map[NodeID]*Node{}
+	for _, node := range a {
+		before[node.Id] = node
+	}
+
+	after := map[NodeID]*Node{}
+	for _, node := range b {
+		after[node.Id] = node
+		if old, ok := before[node.Id]; !ok {
+			d.NodesAdded = append(d.NodesAdded, node.Id)
+		} else if old.Status != node.Status {
+			d.NodeStatus = append(d.NodeStatus, Change{Id: node.Id.String(), From: string(old.Status), To: string(node.Status)})
+		}
+	}
+
+	for _, node := range a {
+		if _, ok := after[node.Id]; !ok {
+			d.NodesRemoved = append(d.NodesRemoved, node.Id)
+		}
+	}
+}
+
+func (d *ClusterDiff) diffDatabases(a, b Databases) {
+	before := map[DBID]*Database{}
+	for _, db := range a {
+		before[db.Id] = db
+	}
+
+	after := map[DBID]*Database{}
+	for _, db := range b {
+		after[db.Id] = db
+		if old, ok := before[db.Id]; !ok {
+			d.DatabasesCreated = append(d.DatabasesCreated, db.Id)
+		} else if old.MasterShards != db.MasterShards {
+			d.DatabasesResharded = append(d.DatabasesResharded, ReshardChange{Id: db.Id, Name: db.Name, From: old.MasterShards, To: db.MasterShards})
+		}
+	}
+
+	for _, db := range a {
+		if _, ok := after[db.Id]; !ok {
+			d.DatabasesDeleted = append(d.DatabasesDeleted, db.Id)
+		}
+	}
+}
+
+func (d *ClusterDiff) diffShards(a, b Shards) {
+	before := map[ShardID]*Shard{}
+	for _, shard := range a {
+		before[shard.Id] = shard
+	}
+
+	after := map[ShardID]*Shard{}
+	for _, shard := range b {
+		after[shard.Id] = shard
+		old, ok := before[shard.Id]
+		if !ok {
+			d.ShardsAdded = append(d.ShardsAdded, shard.Id)
+			continue
+		}
+
+		if old.NodeId != shard.NodeId {
+			d.ShardsMoved = append(d.ShardsMoved, Change{Id: shard.Id.String(), DBId: shard.DBId, From: old.NodeId.String(), To: shard.NodeId.String()})
+		}
+		if old.Role != shard.Role {
+			d.ShardRoles = append(d.ShardRoles, Change{Id: shard.Id.String(), DBId: shard.DBId, From: string(old.Role), To: string(shard.Role)})
+		}
+		if old.UsedMemory != shard.UsedMemory {
+			d.Memory = append(d.Memory, MemoryDelta{
+				Id:    shard.Id,
+				DBId:  shard.DBId,
+				From:  old.UsedMemory,
+				To:    shard.UsedMemory,
+				Delta: shard.UsedMemory - old.UsedMemory,
+			})
+		}
+	}
+
+	for _, shard := range a {
+		if _, ok := after[shard.Id]; !ok {
+			d.ShardsRemoved = append(d.ShardsRemoved, shard.Id)
+		}
+	}
+}
+
+// endpoints can be bound to more than one node so the set of nodes for each
+// endpoint is compared.
+func (d *ClusterDiff) diffEndpoints(a, b Endpoints) {
+	before := endpointNodes(a)
+	after := endpointNodes(b)
+	seen := map[EndpointID]bool{}
+
+	for _, endpoint := range b {
+		if old, ok := before[endpoint.Id]; ok && !seen[endpoint.Id] && old != after[endpoint.Id] {
+			d.EndpointsMoved = append(d.EndpointsMoved, Change{Id: endpoint.Id.String(), DBId: endpoint.DBId, From: old, To: after[endpoint.Id]})
+		}
+		seen[endpoint.Id] = true
+	}
+}
+
+// endpointNodes returns the sorted, comma separated list of nodes for each endpoint
+func endpointNodes(endpoints Endpoints) map[EndpointID]string {
+	nodes := map[EndpointID][]NodeID{}
+	for _, endpoint := range endpoints {
+		nodes[endpoint.Id] = append(nodes[endpoint.Id], endpoint.NodeId)
+	}
+
+	joined := map[EndpointID]string{}
+	for id, list := range nodes {
+		slices.Sort(list)
+		names := []string{}
+		for _, node := range slices.Compact(list) {
+			names = append(names, node.String())
+		}
+		joined[id] = strings.Join(names, ",")
+	}
+	return joined
+}
+
+// Empty returns true if no changes were found
+func (d *ClusterDiff) Empty() bool {
+	return len(d.NodesAdded)+len(d.NodesRemoved)+len(d.NodeStatus)+
+		len(d.DatabasesCreated)+len(d.DatabasesDeleted)+len(d.DatabasesResharded)+
+		len(d.ShardsAdded)+len(d.ShardsRemoved)+len(d.ShardsMoved)+len(d.ShardRoles)+
+		len(d.EndpointsMoved)+len(d.Memory) == 0
+}
+
+func (d *ClusterDiff) JSON() (string, error) {
+	if out, err := json.Marshal(d); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+// Text renders the changes in a human readable form, one change per line.
+func (d *ClusterDiff) Text() string {
+	out := &strings.Builder{}
+
+	fmt.Fprintf(out, "changes from %s to %s\n", d.From.Format(time.RFC3339), d.To.Format(time.RFC3339))
+	if d.Empty() {
+		fmt.Fprintln(out, "no changes")
+		return out.String()
+	}
+
+	for _, id := range d.NodesAdded {
+		fmt.Fprintf(out, "node %s added\n", id)
+	}
+	for _, id := range d.NodesRemoved {
+		fmt.Fprintf(out, "node %s removed\n", id)
+	}
+	for _, c := range d.NodeStatus {
+		fmt.Fprintf(out, "node %s status changed from %s to %s\n", c.Id, c.From, c.To)
+	}
+	for _, id := range d.DatabasesCreated {
+		fmt.Fprintf(out, "database %s created\n", id)
+	}
+	for _, id := range d.DatabasesDeleted {
+		fmt.Fprintf(out, "database %s deleted\n", id)
+	}
+	for _, c := range d.DatabasesResharded {
+		fmt.Fprintf(out, "database %s (%s) resharded from %d to %d shards\n", c.Id, c.Name, c.From, c.To)
+	}
+	for _, id := range d.ShardsAdded {
+		fmt.Fprintf(out, "shard %s added\n", id)
+	}
+	for _, id := range d.ShardsRemoved {
+		fmt.Fprintf(out, "shard %s removed\n", id)
+	}
+	for _, c := range d.ShardsMoved {
+		fmt.Fprintf(out, "shard %s (%s) moved from %s to %s\n", c.Id, c.DBId, c.From, c.To)
+	}
+	for _, c := range d.ShardRoles {
+		fmt.Fprintf(out, "shard %s (%s) role changed from %s to %s\n", c.Id, c.DBId, c.From, c.To)
+	}
+	for _, c := range d.EndpointsMoved {
+		fmt.Fprintf(out, "endpoint %s (%s) moved from %s to %s\n", c.Id, c.DBId, c.From, c.To)
+	}
+	for _, m := range d.Memory {
+		fmt.Fprintf(out, "shard %s (%s) used memory %0.2fGB -> %0.2fGB (%+0.2fGB)\n", m.Id, m.DBId, m.From, m.To, m.Delta)
+	}
+
+	return out.String()
+}
@@ -0,0 +1,33 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before, err := NewClusterInfo("before", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	changed := bytes.Replace(rladmin, []byte("redis:5   node:7  master 0-16383     10.33GB"), []byte("redis:5   node:8  slave  0-16383     11.33GB"), 1)
+	changed = bytes.Replace(changed, []byte("node:14 slave  10.10.21.17 12.20.227.118    node14   0       0      0KB               0/0    2     5.47GB/7.77GB     0KB/0KB          6.2.18-49 b799d6 us-central-3 OK  "),
+		[]byte("node:14 slave  10.10.21.17 12.20.227.118    node14   0       0      0KB               0/0    2     5.47GB/7.77GB     0KB/0KB          6.2.18-49 b799d6 us-central-3 DOWN"), 1)
+	after, err := NewClusterInfo("after", bytes.NewReader(changed))
+	assert.Nil(t, err)
+
+	assert.True(t, Diff(before, before).Empty())
+
+	d := Diff(before, after)
+	assert.Equal(t, []Change{{Id: "redis:5", DBId: 10567021, From: "node:7", To: "node:8"}}, d.ShardsMoved)
+	assert.Equal(t, []Change{{Id: "redis:5", DBId: 10567021, From: "master", To: "slave"}}, d.ShardRoles)
+	assert.Equal(t, []Change{{Id: "node:14", From: "OK", To: "DOWN"}}, d.NodeStatus)
+	if assert.Len(t, d.Memory, 1) {
+		assert.InDelta(t, 1.0, float64(d.Memory[0].Delta), 0.001)
+	}
+	assert.Contains(t, d.Text(), "shard redis:5 (db:10567021) moved from node:7 to node:8")
+}
@@ -5,44 +5,43 @@ Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
 package clusterinfo
 
 import (
-	"bytes"
 	"encoding/json"
 	"time"
 
 	"github.com/gocarina/gocsv"
-	"github.com/goslogan/fw"
 )
 
 type Endpoint struct {
-	Key            string       `columh:"-" json:"key" csv:"key"`
-	Id             string       `column:"ID" json:"id" csv:"endpointId"`
-	DBId           string       `column:"DB:ID" json:"dbId" csv:"dbid"`
-	Name           string       `column:"NAME" json:"name" csv:"name"`
-	Node           string       `column:"NODE" json:"node" csv:"node"`
-	Role           string       `column:"ROLE" json:"role" csv:"endpointRole"`
-	SSL            bool         `column:"SSL" json:"ssl" csv:"ssl"`
-	WatchdogStatus string       `column:"WATCHDOG_STATUS" json:"watchdogStatus" csv:"watchDogStatus"`
-	TimeStamp      time.Time    `json:"timeStamp" csv:"timeStamp" column:"-"`
-	parent         *ClusterInfo `csv:"-" json:"-"`
+	Key            string         `columh:"-" json:"key" csv:"key"`
+	Id             EndpointID     `column:"ID" json:"id" csv:"endpointId"`
+	DBId           DBID           `column:"DB:ID" json:"dbId" csv:"dbid"`
+	Name           string         `column:"NAME" json:"name" csv:"name"`
+	NodeId         NodeID         `column:"NODE" json:"node" csv:"node"`
+	Role           ProxyPolicy    `column:"ROLE" json:"role" csv:"endpointRole"`
+	SSL            bool           `column:"SSL" json:"ssl" csv:"ssl"`
+	WatchdogStatus WatchdogStatus `column:"WATCHDOG_STATUS" json:"watchdogStatus" csv:"watchDogStatus"`
+	TimeStamp      time.Time      `json:"timeStamp" csv:"timeStamp" column:"-"`
+	parent         *ClusterInfo   `csv:"-" json:"-"`
 }
 
 type Endpoints []*Endpoint
 
 func (c *Chunks) ParseEndpoints(parent *ClusterInfo) (Endpoints, error) {
-	endpoints := []*Endpoint{}
-	decoder := fw.NewDecoder(bytes.NewReader(c.Endpoints))
-	decoder.IgnoreEmptyRecords = true
+	endpoints, _, err := c.parseEndpoints(parent, false)
+	return endpoints, err
+}
 
-	err := decoder.Decode(&endpoints)
+func (c *Chunks) parseEndpoints(parent *ClusterInfo, lenient bool) (Endpoints, ParseIssues, error) {
+	endpoints, issues, err := decodeChunk[Endpoint](c, ChunkEndpoints, c.Endpoints, lenient)
 
-	if err != nil {
+	if err == nil {
 		for _, e := range endpoints {
 			e.parent = parent
 			e.Key = parent.Key
 			e.TimeStamp = parent.TimeStamp
 		}
 	}
-	return endpoints, err
+	return endpoints, issues, err
 }
 
 func (e Endpoints) JSON() (string, error) {
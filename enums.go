@@ -0,0 +1,256 @@
+/*
+enums.go provides typed values for the roles, statuses and modes reported by rladmin
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"slices"
+	"strings"
+)
+
+// Each type below lists the values known to the package. Known values are normalised to
+// the constants, ignoring case and surrounding space. Any other value is unknown: it is kept
+// exactly as it appears in the rladmin output, spaces included, and IsUnknown returns true.
+// Known returns the constant for a value, or the Unknown constant of its type, so a switch
+// can handle unknown values explicitly without losing the original text.
+
+type ShardRole string
+type NodeRole string
+type NodeStatus string
+type ShardStatus string
+type WatchdogStatus string
+type DatabaseStatus string
+type ReplicationMode string
+type PersistenceMode string
+type ShardPlacement string
+type ProxyPolicy string
+type ExecState string
+
+const (
+	ShardRoleMaster  ShardRole = "master"
+	ShardRoleReplica ShardRole = "slave"
+	ShardRoleUnknown ShardRole = "unknown"
+
+	NodeRoleMaster  NodeRole = "master"
+	NodeRoleReplica NodeRole = "slave"
+	NodeRoleUnknown NodeRole = "unknown"
+
+	NodeStatusOK      NodeStatus = "OK"
+	NodeStatusDown    NodeStatus = "DOWN"
+	NodeStatusUnknown NodeStatus = "unknown"
+
+	ShardStatusOK      ShardStatus = "OK"
+	ShardStatusDown    ShardStatus = "DOWN"
+	ShardStatusUnknown ShardStatus = "unknown"
+
+	WatchdogStatusOK      WatchdogStatus = "OK"
+	WatchdogStatusUnknown WatchdogStatus = "unknown"
+
+	DatabaseStatusActive              DatabaseStatus = "active"
+	DatabaseStatusActiveChangePending DatabaseStatus = "active-change-pending"
+	DatabaseStatusPending             DatabaseStatus = "pending"
+	DatabaseStatusImportPending       DatabaseStatus = "import-pending"
+	DatabaseStatusDeletePending       DatabaseStatus = "delete-pending"
+	DatabaseStatusCreationFailed      DatabaseStatus = "creation-failed"
+	DatabaseStatusRecovery            DatabaseStatus = "recovery"
+	DatabaseStatusUnknown             DatabaseStatus = "unknown"
+
+	ReplicationEnabled  ReplicationMode = "enabled"
+	ReplicationDisabled ReplicationMode = "disabled"
+	ReplicationUnknown  ReplicationMode = "unknown"
+
+	PersistenceDisabled PersistenceMode = "disabled"
+	PersistenceAOF      PersistenceMode = "aof"
+	PersistenceSnapshot PersistenceMode = "snapshot"
+	PersistenceUnknown  PersistenceMode = "unknown"
+
+	PlacementDense   ShardPlacement = "dense"
+	PlacementSparse  ShardPlacement = "sparse"
+	PlacementUnknown ShardPlacement = "unknown"
+
+	PolicySingle          ProxyPolicy = "single"
+	PolicyAllMasterShards ProxyPolicy = "all-master-shards"
+	PolicyAllNodes        ProxyPolicy = "all-nodes"
+	PolicyUnknown         ProxyPolicy = "unknown"
+
+	ExecStateNone    ExecState = "N/A"
+	ExecStateUnknown ExecState = "unknown"
+)
+
+var (
+	shardRoles       = []ShardRole{ShardRoleMaster, ShardRoleReplica}
+	nodeRoles        = []NodeRole{NodeRoleMaster, NodeRoleReplica}
+	nodeStatuses     = []NodeStatus{NodeStatusOK, NodeStatusDown}
+	shardStatuses    = []ShardStatus{ShardStatusOK, ShardStatusDown}
+	watchdogStatuses = []WatchdogStatus{WatchdogStatusOK}
+	databaseStatuses = []DatabaseStatus{DatabaseStatusActive, DatabaseStatusActiveChangePending, DatabaseStatusPending,
+		DatabaseStatusImportPending, DatabaseStatusDeletePending, DatabaseStatusCreationFailed, DatabaseStatusRecovery}
+	replicationModes = []ReplicationMode{ReplicationEnabled, ReplicationDisabled}
+	persistenceModes = []PersistenceMode{PersistenceDisabled, PersistenceAOF, PersistenceSnapshot}
+	placements       = []ShardPlacement{PlacementDense, PlacementSparse}
+	proxyPolicies    = []ProxyPolicy{PolicySingle, PolicyAllMasterShards, PolicyAllNodes}
+	execStates       = []ExecState{ExecStateNone}
+)
+
+// parseEnum returns the known value matching text, ignoring case and surrounding space, or
+// the untouched text if there isn't one
+func parseEnum[T ~string](text []byte, known []T) T {
+	value := strings.TrimSpace(string(text))
+	for _, k := range known {
+		if strings.EqualFold(string(k), value) {
+			return k
+		}
+	}
+	return T(text)
+}
+
+// knownEnum returns value if it is known and unknown otherwise
+func knownEnum[T ~string](value T, known []T, unknown T) T {
+	if slices.Contains(known, value) {
+		return value
+	}
+	return unknown
+}
+
+func (r *ShardRole) UnmarshalText(text []byte) error {
+	*r = parseEnum(text, shardRoles)
+	return nil
+}
+
+func (r ShardRole) MarshalText() ([]byte, error) { return []byte(r), nil }
+func (r ShardRole) IsUnknown() bool              { return !slices.Contains(shardRoles, r) }
+
+func (r ShardRole) Known() ShardRole {
+	return knownEnum(r, shardRoles, ShardRoleUnknown)
+}
+
+func (r *NodeRole) UnmarshalText(text []byte) error {
+	*r = parseEnum(text, nodeRoles)
+	return nil
+}
+
+func (r NodeRole) MarshalText() ([]byte, error) { return []byte(r), nil }
+func (r NodeRole) IsUnknown() bool              { return !slices.Contains(nodeRoles, r) }
+
+func (r NodeRole) Known() NodeRole {
+	return knownEnum(r, nodeRoles, NodeRoleUnknown)
+}
+
+func (s *NodeStatus) UnmarshalText(text []byte) error {
+	*s = parseEnum(text, nodeStatuses)
+	return nil
+}
+
+func (s NodeStatus) MarshalText() ([]byte, error) { return []byte(s), nil }
+func (s NodeStatus) IsUnknown() bool              { return !slices.Contains(nodeStatuses, s) }
+func (s NodeStatus) IsHealthy() bool              { return s == NodeStatusOK }
+
+func (s NodeStatus) Known() NodeStatus {
+	return knownEnum(s, nodeStatuses, NodeStatusUnknown)
+}
+
+func (s *ShardStatus) UnmarshalText(text []byte) error {
+	*s = parseEnum(text, shardStatuses)
+	return nil
+}
+
+func (s ShardStatus) MarshalText() ([]byte, error) { return []byte(s), nil }
+func (s ShardStatus) IsUnknown() bool              { return !slices.Contains(shardStatuses, s) }
+func (s ShardStatus) IsHealthy() bool              { return s == ShardStatusOK }
+
+func (s ShardStatus) Known() ShardStatus {
+	return knownEnum(s, shardStatuses, ShardStatusUnknown)
+}
+
+func (s *WatchdogStatus) UnmarshalText(text []byte) error {
+	*s = parseEnum(text, watchdogStatuses)
+	return nil
+}
+
+func (s WatchdogStatus) MarshalText() ([]byte, error) { return []byte(s), nil }
+func (s WatchdogStatus) IsUnknown() bool              { return !slices.Contains(watchdogStatuses, s) }
+func (s WatchdogStatus) IsHealthy() bool              { return s == WatchdogStatusOK }
+
+func (s WatchdogStatus) Known() WatchdogStatus {
+	return knownEnum(s, watchdogStatuses, WatchdogStatusUnknown)
+}
+
+func (s *DatabaseStatus) UnmarshalText(text []byte) error {
+	*s = parseEnum(text, databaseStatuses)
+	return nil
+}
+
+func (s DatabaseStatus) MarshalText() ([]byte, error) { return []byte(s), nil }
+func (s DatabaseStatus) IsUnknown() bool              { return !slices.Contains(databaseStatuses, s) }
+func (s DatabaseStatus) IsHealthy() bool              { return s == DatabaseStatusActive }
+
+func (s DatabaseStatus) Known() DatabaseStatus {
+	return knownEnum(s, databaseStatuses, DatabaseStatusUnknown)
+}
+
+func (m *ReplicationMode) UnmarshalText(text []byte) error {
+	*m = parseEnum(text, replicationModes)
+	return nil
+}
+
+func (m ReplicationMode) MarshalText() ([]byte, error) { return []byte(m), nil }
+func (m ReplicationMode) IsUnknown() bool              { return !slices.Contains(replicationModes, m) }
+func (m ReplicationMode) Enabled() bool                { return m == ReplicationEnabled }
+
+func (m ReplicationMode) Known() ReplicationMode {
+	return knownEnum(m, replicationModes, ReplicationUnknown)
+}
+
+func (m *PersistenceMode) UnmarshalText(text []byte) error {
+	*m = parseEnum(text, persistenceModes)
+	return nil
+}
+
+func (m PersistenceMode) MarshalText() ([]byte, error) { return []byte(m), nil }
+func (m PersistenceMode) IsUnknown() bool              { return !slices.Contains(persistenceModes, m) }
+
+// Enabled returns true for the known persistence modes which write to disk
+func (m PersistenceMode) Enabled() bool {
+	return slices.Contains([]PersistenceMode{PersistenceAOF, PersistenceSnapshot}, m)
+}
+
+func (m PersistenceMode) Known() PersistenceMode {
+	return knownEnum(m, persistenceModes, PersistenceUnknown)
+}
+
+func (p *ShardPlacement) UnmarshalText(text []byte) error {
+	*p = parseEnum(text, placements)
+	return nil
+}
+
+func (p ShardPlacement) MarshalText() ([]byte, error) { return []byte(p), nil }
+func (p ShardPlacement) IsUnknown() bool              { return !slices.Contains(placements, p) }
+
+func (p ShardPlacement) Known() ShardPlacement {
+	return knownEnum(p, placements, PlacementUnknown)
+}
+
+func (p *ProxyPolicy) UnmarshalText(text []byte) error {
+	*p = parseEnum(text, proxyPolicies)
+	return nil
+}
+
+func (p ProxyPolicy) MarshalText() ([]byte, error) { return []byte(p), nil }
+func (p ProxyPolicy) IsUnknown() bool              { return !slices.Contains(proxyPolicies, p) }
+
+func (p ProxyPolicy) Known() ProxyPolicy {
+	return knownEnum(p, proxyPolicies, PolicyUnknown)
+}
+
+func (s *ExecState) UnmarshalText(text []byte) error {
+	*s = parseEnum(text, execStates)
+	return nil
+}
+
+func (s ExecState) MarshalText() ([]byte, error) { return []byte(s), nil }
+func (s ExecState) IsUnknown() bool              { return !slices.Contains(execStates, s) }
+
+func (s ExecState) Known() ExecState {
+	return knownEnum(s, execStates, ExecStateUnknown)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnums(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	shard := info.Shard(5)
+	assert.Equal(t, ShardRoleMaster, shard.Role)
+	assert.True(t, shard.Status.IsHealthy())
+	assert.True(t, shard.WatchdogStatus.IsHealthy())
+	assert.Equal(t, ShardRoleReplica, info.Shard(6).Role)
+
+	db := info.Database(10567021)
+	assert.Equal(t, DatabaseStatusActive, db.Status)
+	assert.True(t, db.Replication.Enabled())
+	assert.Equal(t, PersistenceAOF, db.Persistence)
+	assert.Equal(t, PlacementDense, db.Placement)
+	assert.Equal(t, NodeRoleMaster, info.Node(3).Role)
+
+	var role ShardRole
+	assert.Nil(t, role.UnmarshalText([]byte(" Master ")))
+	assert.Equal(t, ShardRoleMaster, role)
+	assert.False(t, role.IsUnknown())
+
+	var status NodeStatus
+	assert.Nil(t, status.UnmarshalText([]byte("MAINTENANCE")))
+	assert.True(t, status.IsUnknown())
+	assert.False(t, status.IsHealthy())
+	assert.Equal(t, "MAINTENANCE", string(status))
+	assert.Equal(t, NodeStatusUnknown, status.Known())
+	assert.Equal(t, NodeStatusOK, NodeStatusOK.Known())
+	assert.Equal(t, PolicyUnknown, ProxyPolicy("round-robin").Known())
+
+	// only known modes which write to disk are enabled
+	assert.True(t, PersistenceAOF.Enabled())
+	assert.True(t, PersistenceSnapshot.Enabled())
+	assert.False(t, PersistenceDisabled.Enabled())
+	assert.False(t, PersistenceMode("").Enabled())
+	assert.False(t, PersistenceMode("rdb-maybe").Enabled())
+	assert.Equal(t, PersistenceUnknown, PersistenceMode("rdb-maybe").Known())
+
+	// unknown values keep their original text
+	var raw NodeStatus
+	assert.Nil(t, raw.UnmarshalText([]byte(" Maintenance ")))
+	assert.Equal(t, NodeStatus(" Maintenance "), raw)
+
+	out, err := json.Marshal(struct {
+		Status NodeStatus      `json:"status"`
+		Mode   PersistenceMode `json:"mode"`
+	}{status, PersistenceSnapshot})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"status":"MAINTENANCE","mode":"snapshot"}`, string(out))
+}
@@ -0,0 +1,141 @@
+/*
+ids.go provides typed ids for nodes, databases, shards and endpoints
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NodeID is the number of a node, written as "node:N" by rladmin
+type NodeID uint64
+
+// DBID is the number of a database, written as "db:N" by rladmin
+type DBID uint64
+
+// ShardID is the number of a shard, written as "redis:N" by rladmin
+type ShardID uint64
+
+// EndpointID identifies an endpoint of a database, written as "endpoint:DB:N" by rladmin
+type EndpointID struct {
+	DB     DBID
+	Number uint64
+}
+
+const (
+	nodePrefix     = "node:"
+	dbPrefix       = "db:"
+	shardPrefix    = "redis:"
+	endpointPrefix = "endpoint:"
+)
+
+// The zero value of each id is no id and is written as an empty string.
+
+func (id NodeID) String() string  { return formatId(nodePrefix, uint64(id)) }
+func (id DBID) String() string    { return formatId(dbPrefix, uint64(id)) }
+func (id ShardID) String() string { return formatId(shardPrefix, uint64(id)) }
+func (id EndpointID) String() string {
+	if id == (EndpointID{}) {
+		return ""
+	}
+	return fmt.Sprintf("%s%d:%d", endpointPrefix, id.DB, id.Number)
+}
+
+func formatId(prefix string, id uint64) string {
+	if id == 0 {
+		return ""
+	}
+	return prefix + strconv.FormatUint(id, 10)
+}
+
+// ParseNodeID parses "node:N", "*node:N" (the node rladmin was run on) or just "N"
+func ParseNodeID(s string) (NodeID, error) {
+	id, err := parseId(strings.TrimPrefix(strings.TrimSpace(s), "*"), nodePrefix, "node id")
+	return NodeID(id), err
+}
+
+// ParseDBID parses "db:N" or just "N"
+func ParseDBID(s string) (DBID, error) {
+	id, err := parseId(s, dbPrefix, "database id")
+	return DBID(id), err
+}
+
+// ParseShardID parses "redis:N" or just "N"
+func ParseShardID(s string) (ShardID, error) {
+	id, err := parseId(s, shardPrefix, "shard id")
+	return ShardID(id), err
+}
+
+// ParseEndpointID parses "endpoint:DB:N" or just "DB:N"
+func ParseEndpointID(s string) (EndpointID, error) {
+	text := strings.TrimPrefix(strings.TrimSpace(s), endpointPrefix)
+	if text == "" {
+		return EndpointID{}, nil
+	}
+
+	db, number, found := strings.Cut(text, ":")
+	if !found {
+		return EndpointID{}, fmt.Errorf(errorString, s, "endpoint id", fmt.Errorf("missing endpoint number"))
+	}
+	dbId, err := strconv.ParseUint(db, 10, 64)
+	if err != nil {
+		return EndpointID{}, fmt.Errorf(errorString, s, "endpoint id", err)
+	}
+	n, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return EndpointID{}, fmt.Errorf(errorString, s, "endpoint id", err)
+	}
+	return EndpointID{DB: DBID(dbId), Number: n}, nil
+}
+
+func parseId(s, prefix, name string) (uint64, error) {
+	text := strings.TrimPrefix(strings.TrimSpace(s), prefix)
+	if text == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf(errorString, s, name, err)
+	}
+	return id, nil
+}
+
+func (id NodeID) MarshalText() ([]byte, error) { return []byte(id.String()), nil }
+
+func (id *NodeID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseNodeID(string(text))
+	return err
+}
+
+func (id DBID) MarshalText() ([]byte, error) { return []byte(id.String()), nil }
+
+func (id *DBID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseDBID(string(text))
+	return err
+}
+
+func (id ShardID) MarshalText() ([]byte, error) { return []byte(id.String()), nil }
+
+func (id *ShardID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseShardID(string(text))
+	return err
+}
+
+func (id EndpointID) MarshalText() ([]byte, error) { return []byte(id.String()), nil }
+
+func (id *EndpointID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseEndpointID(string(text))
+	return err
+}
+
+// Compare orders endpoint ids by database and then number
+func (id EndpointID) Compare(other EndpointID) int {
+	if c := cmp.Compare(id.DB, other.DB); c != 0 {
+		return c
+	}
+	return cmp.Compare(id.Number, other.Number)
+}
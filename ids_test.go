@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIds(t *testing.T) {
+	for input, expected := range map[string]NodeID{"node:1": 1, "*node:2": 2, "3": 3, "": 0} {
+		id, err := ParseNodeID(input)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, id)
+	}
+
+	db, err := ParseDBID("db:10567021")
+	assert.Nil(t, err)
+	assert.Equal(t, DBID(10567021), db)
+
+	shard, err := ParseShardID("redis:943")
+	assert.Nil(t, err)
+	assert.Equal(t, ShardID(943), shard)
+
+	endpoint, err := ParseEndpointID("endpoint:10:1")
+	assert.Nil(t, err)
+	assert.Equal(t, EndpointID{DB: 10, Number: 1}, endpoint)
+	assert.Equal(t, "endpoint:10:1", endpoint.String())
+
+	_, err = ParseNodeID("node:x")
+	assert.NotNil(t, err)
+	_, err = ParseEndpointID("endpoint:10")
+	assert.NotNil(t, err)
+}
+
+func TestIdsMarshal(t *testing.T) {
+	out, err := json.Marshal(struct {
+		Node  NodeID
+		Shard ShardID
+		None  DBID
+	}{1, 943, 0})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Node":"node:1","Shard":"redis:943","None":""}`, string(out))
+
+	var id NodeID
+	assert.Nil(t, json.Unmarshal([]byte(`"*node:2"`), &id))
+	assert.Equal(t, NodeID(2), id)
+}
+
+func TestIdsOrder(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+
+	shards := info.Shards.ForDB(10)
+	for n := 1; n < len(shards); n++ {
+		assert.Less(t, shards[n-1].Id, shards[n].Id)
+	}
+
+	assert.Equal(t, info.Node(3), info.LookupNode("node:3"))
+	assert.Equal(t, info.Node(3), info.LookupNode("3"))
+	assert.Equal(t, info.Shard(2), info.LookupShard("redis:2"))
+	assert.Equal(t, info.Database(10), info.LookupDatabase("db:10"))
+	assert.Nil(t, info.LookupNode("node:x"))
+}
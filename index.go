@@ -0,0 +1,203 @@
+/*
+index.go provides id indexes over the cluster information and navigation between related entities
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"cmp"
+	"slices"
+)
+
+type clusterIndex struct {
+	nodes           map[NodeID]*Node
+	databases       map[DBID]*Database
+	shards          map[ShardID]*Shard
+	endpoints       map[EndpointID]Endpoints
+	shardsByDB      map[DBID]Shards
+	shardsByNode    map[NodeID]Shards
+	endpointsByDB   map[DBID]Endpoints
+	endpointsByNode map[NodeID]Endpoints
+}
+
+// Reindex rebuilds the id indexes used for lookups and navigation. Indexes are built
+// by every constructor and on first use for cluster information built by hand, so
+// lookups are safe from more than one goroutine. The slices returned by navigation
+// methods such as Node.Shards are copies. Reindex must be called if the Nodes,
+// Databases, Shards or Endpoints are modified afterwards.
+func (c *ClusterInfo) Reindex() {
+	c.index.Store(c.buildIndex())
+}
+
+func (c *ClusterInfo) buildIndex() *clusterIndex {
+	index := &clusterIndex{
+		nodes:           make(map[NodeID]*Node, len(c.Nodes)),
+		databases:       make(map[DBID]*Database, len(c.Databases)),
+		shards:          make(map[ShardID]*Shard, len(c.Shards)),
+		endpoints:       make(map[EndpointID]Endpoints, len(c.Endpoints)),
+		shardsByDB:      make(map[DBID]Shards, len(c.Databases)),
+		shardsByNode:    make(map[NodeID]Shards, len(c.Nodes)),
+		endpointsByDB:   make(map[DBID]Endpoints, len(c.Databases)),
+		endpointsByNode: make(map[NodeID]Endpoints, len(c.Nodes)),
+	}
+
+	for _, node := range c.Nodes {
+		index.nodes[node.Id] = node
+	}
+
+	for _, db := range c.Databases {
+		index.databases[db.Id] = db
+	}
+
+	for _, shard := range c.Shards {
+		index.shards[shard.Id] = shard
+		index.shardsByDB[shard.DBId] = append(index.shardsByDB[shard.DBId], shard)
+		index.shardsByNode[shard.NodeId] = append(index.shardsByNode[shard.NodeId], shard)
+	}
+
+	// keep the same ordering as Shards.ForDB
+	for _, shards := range index.shardsByDB {
+		slices.SortStableFunc(shards, func(a *Shard, b *Shard) int {
+			return cmp.Compare(a.Id, b.Id)
+		})
+	}
+
+	// endpoint ids are repeated when an endpoint is bound to more than one node
+	for _, endpoint := range c.Endpoints {
+		index.endpoints[endpoint.Id] = append(index.endpoints[endpoint.Id], endpoint)
+		index.endpointsByDB[endpoint.DBId] = append(index.endpointsByDB[endpoint.DBId], endpoint)
+		index.endpointsByNode[endpoint.NodeId] = append(index.endpointsByNode[endpoint.NodeId], endpoint)
+	}
+
+	return index
+}
+
+// indexes returns the indexes, building them if needed. Concurrent first lookups may each
+// build them but only the first to finish is kept.
+func (c *ClusterInfo) indexes() *clusterIndex {
+	if index := c.index.Load(); index != nil {
+		return index
+	}
+	index := c.buildIndex()
+	if c.index.CompareAndSwap(nil, index) {
+		return index
+	}
+	return c.index.Load()
+}
+
+// Node returns the node with the given id or nil if there isn't one.
+func (c *ClusterInfo) Node(id NodeID) *Node {
+	return c.indexes().nodes[id]
+}
+
+// Database returns the database with the given id or nil if there isn't one.
+func (c *ClusterInfo) Database(id DBID) *Database {
+	return c.indexes().databases[id]
+}
+
+// Shard returns the shard with the given id or nil if there isn't one.
+func (c *ClusterInfo) Shard(id ShardID) *Shard {
+	return c.indexes().shards[id]
+}
+
+// Endpoint returns every binding of the endpoint with the given id. There is one
+// for each node the endpoint is bound to.
+func (c *ClusterInfo) Endpoint(id EndpointID) Endpoints {
+	return slices.Clone(c.indexes().endpoints[id])
+}
+
+// LookupNode returns the node with an id given as "node:3" or "3", or nil if there isn't one.
+func (c *ClusterInfo) LookupNode(id string) *Node {
+	if nodeId, err := ParseNodeID(id); err == nil {
+		return c.Node(nodeId)
+	}
+	return nil
+}
+
+// LookupDatabase returns the database with an id given as "db:3" or "3", or nil if there isn't one.
+func (c *ClusterInfo) LookupDatabase(id string) *Database {
+	if dbId, err := ParseDBID(id); err == nil {
+		return c.Database(dbId)
+	}
+	return nil
+}
+
+// LookupShard returns the shard with an id given as "redis:3" or "3", or nil if there isn't one.
+func (c *ClusterInfo) LookupShard(id string) *Shard {
+	if shardId, err := ParseShardID(id); err == nil {
+		return c.Shard(shardId)
+	}
+	return nil
+}
+
+// LookupEndpoint returns the bindings of an endpoint with an id given as "endpoint:3:1" or "3:1".
+func (c *ClusterInfo) LookupEndpoint(id string) Endpoints {
+	if endpointId, err := ParseEndpointID(id); err == nil {
+		return c.Endpoint(endpointId)
+	}
+	return nil
+}
+
+// Database returns the database the shard belongs to
+func (s *Shard) Database() *Database {
+	if s.parent == nil {
+		return nil
+	}
+	return s.parent.Database(s.DBId)
+}
+
+// Node returns the node the shard is running on
+func (s *Shard) Node() *Node {
+	if s.parent == nil {
+		return nil
+	}
+	return s.parent.Node(s.NodeId)
+}
+
+// Shards returns the shards running on the node
+func (n *Node) Shards() Shards {
+	if n.parent == nil {
+		return Shards{}
+	}
+	return slices.Clone(n.parent.indexes().shardsByNode[n.Id])
+}
+
+// Endpoints returns the endpoints bound to the node
+func (n *Node) Endpoints() Endpoints {
+	if n.parent == nil {
+		return Endpoints{}
+	}
+	return slices.Clone(n.parent.indexes().endpointsByNode[n.Id])
+}
+
+// Shards returns the shards of the database, sorted in Id order
+func (db *Database) Shards() Shards {
+	if db.parent == nil {
+		return Shards{}
+	}
+	return slices.Clone(db.parent.indexes().shardsByDB[db.Id])
+}
+
+// Endpoints returns the endpoints of the database
+func (db *Database) Endpoints() Endpoints {
+	if db.parent == nil {
+		return Endpoints{}
+	}
+	return slices.Clone(db.parent.indexes().endpointsByDB[db.Id])
+}
+
+// Database returns the database the endpoint belongs to
+func (e *Endpoint) Database() *Database {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent.Database(e.DBId)
+}
+
+// Node returns the node the endpoint is bound to
+func (e *Endpoint) Node() *Node {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent.Node(e.NodeId)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNavigation(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	shard := info.Shard(5)
+	if assert.NotNil(t, shard) {
+		assert.Equal(t, "sudan-02", shard.Database().Name)
+		assert.Equal(t, "node7", shard.Node().HostName)
+		assert.Contains(t, shard.Node().Shards(), shard)
+	}
+
+	db := info.Database(10567021)
+	if assert.NotNil(t, db) {
+		assert.Len(t, db.Shards(), 2)
+		assert.Equal(t, uint16(2), db.ShardCount())
+		if assert.Len(t, db.Endpoints(), 1) {
+			assert.Equal(t, db, db.Endpoints()[0].Database())
+			assert.Equal(t, NodeID(1), db.Endpoints()[0].Node().Id)
+		}
+	}
+
+	assert.Len(t, info.LookupEndpoint("endpoint:11480858:1"), 2)
+	assert.NotEmpty(t, info.Node(3).Endpoints())
+	assert.Nil(t, info.Node(99))
+
+	// returned slices are copies
+	shards := db.Shards()
+	shards[0] = nil
+	assert.NotNil(t, db.Shards()[0])
+}
+
+func TestConcurrentIndex(t *testing.T) {
+	parsed, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	// built by hand so the index is built on first use
+	info := &ClusterInfo{Key: "test", Nodes: parsed.Nodes, Databases: parsed.Databases, Shards: parsed.Shards, Endpoints: parsed.Endpoints}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NotNil(t, info.Shard(5))
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := NewClusterInfo("bench", bytes.NewReader(rladmin)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDatabasesWithNodes(b *testing.B) {
+	info, err := NewClusterInfo("bench", bytes.NewReader(rladmin))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		info.DatabasesWithNodes()
+	}
+}
+
+func BenchmarkShardNavigation(b *testing.B) {
+	info, err := NewClusterInfo("bench", bytes.NewReader(rladmin))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, shard := range info.Shards {
+			shard.Database().Shards()
+			shard.Node().Endpoints()
+		}
+	}
+}
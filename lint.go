@@ -0,0 +1,356 @@
+/*
+lint.go provides a rule based health checker for cluster information
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gocarina/gocsv"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+// Finding is a single problem reported by a lint check
+type Finding struct {
+	Key      string   `json:"key" csv:"key"`
+	Check    string   `json:"check" csv:"check"`
+	Severity Severity `json:"severity" csv:"severity"`
+	Entity   string   `json:"entity" csv:"entity"`
+	Message  string   `json:"message" csv:"message"`
+}
+
+type Findings []*Finding
+
+// LintCheck examines cluster information and returns any problems found
+type LintCheck func(info *ClusterInfo) Findings
+
+type namedCheck struct {
+	name  string
+	check LintCheck
+}
+
+// Linter runs a set of checks against cluster information
+type Linter struct {
+	checks []namedCheck
+}
+
+// DefaultLinter contains the standard checks. ClusterInfo.Lint uses it so
+// checks registered here are run by every caller.
+var DefaultLinter = NewLinter()
+
+// NewLinter returns a linter loaded with the standard checks.
+func NewLinter() *Linter {
+	l := &Linter{}
+	l.Register("master-replica-same-node", checkSameNode)
+	l.Register("master-replica-same-rack", checkSameRack)
+	l.Register("node-status", checkNodeStatus)
+	l.Register("shard-status", checkShardStatus)
+	l.Register("endpoint-status", checkEndpointStatus)
+	l.Register("database-status", checkDatabaseStatus)
+	l.Register("overbooking", checkOverbooking)
+	l.Register("shard-count", checkShardCount)
+	l.Register("cluster-master", checkClusterMaster)
+	return l
+}
+
+// Register adds a check to the linter. A check registered with the name of
+// an existing check replaces it.
+func (l *Linter) Register(name string, check LintCheck) {
+	for n := range l.checks {
+		if l.checks[n].name == name {
+			l.checks[n].check = check
+			return
+		}
+	}
+	l.checks = append(l.checks, namedCheck{name: name, check: check})
+}
+
+// Unregister removes a check from the linter
+func (l *Linter) Unregister(name string) {
+	for n := range l.checks {
+		if l.checks[n].name == name {
+			l.checks = append(l.checks[:n], l.checks[n+1:]...)
+			return
+		}
+	}
+}
+
+// Checks returns the names of the registered checks in the order they run
+func (l *Linter) Checks() []string {
+	names := []string{}
+	for _, c := range l.checks {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Lint runs every registered check and returns the findings. Each finding
+// is labelled with the check that produced it.
+func (l *Linter) Lint(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, c := range l.checks {
+		for _, f := range c.check(info) {
+			f.Check = c.name
+			f.Key = info.Key
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// RegisterLintCheck adds a check to the default linter
+func RegisterLintCheck(name string, check LintCheck) {
+	DefaultLinter.Register(name, check)
+}
+
+// Lint runs the checks registered with the default linter
+func (c *ClusterInfo) Lint() Findings {
+	return DefaultLinter.Lint(c)
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for k, v := range severityNames {
+		if v == string(text) {
+			*s = k
+			return nil
+		}
+	}
+	return fmt.Errorf(errorString, text, "severity", fmt.Errorf("unknown severity"))
+}
+
+// AtLeast returns the findings with at least the given severity
+func (f Findings) AtLeast(severity Severity) Findings {
+	found := Findings{}
+	for _, finding := range f {
+		if finding.Severity >= severity {
+			found = append(found, finding)
+		}
+	}
+	return found
+}
+
+func (f Findings) JSON() (string, error) {
+	if out, err := json.Marshal(f); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (f Findings) CSV(skipHeaders bool) (string, error) {
+	if skipHeaders {
+		return gocsv.MarshalStringWithoutHeaders(f)
+	} else {
+		return gocsv.MarshalString(f)
+	}
+}
+
+func checkSameNode(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, db := range info.RackAffinity() {
+		for _, v := range db.Violations {
+			if v.SameNode {
+				findings = append(findings, &Finding{
+					Severity: SeverityError,
+					Entity:   db.DBId.String(),
+					Message:  fmt.Sprintf("master %s and replica %s are both on %s", v.Master, v.Replica, v.MasterNode),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkSameRack(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, db := range info.RackAffinity() {
+		for _, v := range db.Violations {
+			if !v.SameNode {
+				findings = append(findings, &Finding{
+					Severity: SeverityWarning,
+					Entity:   db.DBId.String(),
+					Message:  fmt.Sprintf("master %s and replica %s are both in rack %s", v.Master, v.Replica, v.MasterRack),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkNodeStatus(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, node := range info.Nodes {
+		if !node.Status.IsHealthy() {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Entity:   node.Id.String(),
+				Message:  fmt.Sprintf("node status is %s", node.Status),
+			})
+		}
+	}
+	return findings
+}
+
+func checkShardStatus(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, shard := range info.Shards {
+		if !shard.Status.IsHealthy() {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Entity:   shard.Id.String(),
+				Message:  fmt.Sprintf("shard status is %s", shard.Status),
+			})
+		}
+		if !shard.WatchdogStatus.IsHealthy() {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Entity:   shard.Id.String(),
+				Message:  fmt.Sprintf("shard watchdog status is %s", shard.WatchdogStatus),
+			})
+		}
+	}
+	return findings
+}
+
+func checkEndpointStatus(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, endpoint := range info.Endpoints {
+		if !endpoint.WatchdogStatus.IsHealthy() {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Entity:   endpoint.Id.String(),
+				Message:  fmt.Sprintf("endpoint watchdog status on %s is %s", endpoint.NodeId, endpoint.WatchdogStatus),
+			})
+		}
+	}
+	return findings
+}
+
+func checkDatabaseStatus(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, db := range info.Databases {
+		if !db.Status.IsHealthy() {
+			findings = append(findings, &Finding{
+				Severity: SeverityWarning,
+				Entity:   db.Id.String(),
+				Message:  fmt.Sprintf("database status is %s", db.Status),
+			})
+		}
+	}
+	return findings
+}
+
+func checkOverbooking(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, node := range info.Nodes {
+		if node.OverbookingDepth < 0 {
+			findings = append(findings, &Finding{
+				Severity: SeverityWarning,
+				Entity:   node.Id.String(),
+				Message:  fmt.Sprintf("node is overbooked by %0.2fGB", -node.OverbookingDepth),
+			})
+		}
+	}
+	return findings
+}
+
+func checkShardCount(info *ClusterInfo) Findings {
+	findings := Findings{}
+	for _, db := range info.Databases {
+		expected := db.MasterShards
+		if db.Replication.Enabled() {
+			expected *= 2
+		}
+		if count := db.ShardCount(); count != expected {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Entity:   db.Id.String(),
+				Message:  fmt.Sprintf("database has %d shards but %d were expected", count, expected),
+			})
+		}
+	}
+	return findings
+}
+
+// checkClusterMaster compares the cluster master reported by the node rladmin was run on with
+// the node roles. A disagreement suggests the nodes don't share a view of the cluster.
+func checkClusterMaster(info *ClusterInfo) Findings {
+	findings := Findings{}
+	if info.Cluster == nil {
+		return findings
+	}
+
+	source := "rladmin"
+	if info.SourceNode != 0 {
+		source = info.SourceNode.String()
+	}
+
+	if info.Cluster.MasterNode == 0 {
+		findings = append(findings, &Finding{
+			Severity: SeverityWarning,
+			Entity:   "cluster",
+			Message:  fmt.Sprintf("%s doesn't report a cluster master", source),
+		})
+		return findings
+	}
+
+	if master := info.Cluster.Master(); master == nil {
+		findings = append(findings, &Finding{
+			Severity: SeverityError,
+			Entity:   info.Cluster.MasterNode.String(),
+			Message:  fmt.Sprintf("%s reports %s as cluster master but it isn't in the node list", source, info.Cluster.MasterNode),
+		})
+	} else {
+		if master.Role != NodeRoleMaster {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Entity:   master.Id.String(),
+				Message:  fmt.Sprintf("%s reports %s as cluster master but its role is %s", source, master.Id, master.Role),
+			})
+		}
+		if info.Cluster.MasterAddress.IP != nil && !master.Address.Equal(info.Cluster.MasterAddress.IP) {
+			findings = append(findings, &Finding{
+				Severity: SeverityWarning,
+				Entity:   master.Id.String(),
+				Message:  fmt.Sprintf("%s reports cluster master address %s but %s has address %s", source, info.Cluster.MasterAddress, master.Id, master.Address),
+			})
+		}
+	}
+
+	for _, node := range info.Nodes {
+		if node.Role == NodeRoleMaster && node.Id != info.Cluster.MasterNode {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Entity:   node.Id.String(),
+				Message:  fmt.Sprintf("node role is master but %s reports %s as cluster master", source, info.Cluster.MasterNode),
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+
+	findings := info.Lint()
+	assert.Empty(t, findings.AtLeast(SeverityError))
+
+	linter := NewLinter()
+	linter.Register("always", func(info *ClusterInfo) Findings {
+		return Findings{{Severity: SeverityInfo, Entity: info.Cluster.MasterNode.String(), Message: "hello"}}
+	})
+	findings = linter.Lint(info)
+	if assert.NotEmpty(t, findings) {
+		last := findings[len(findings)-1]
+		assert.Equal(t, "always", last.Check)
+		assert.Equal(t, "test", last.Key)
+		assert.Equal(t, "node:1", last.Entity)
+	}
+}
+
+func TestLintSameNode(t *testing.T) {
+	moved := bytes.Replace(rsOutput, []byte("redis:41 node:30 master 0-545"), []byte("redis:41 node:12 master 0-545"), 1)
+	info, err := NewClusterInfo("test", bytes.NewReader(moved))
+	assert.Nil(t, err)
+
+	linter := &Linter{}
+	linter.Register("same-node", checkSameNode)
+	findings := linter.Lint(info)
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, SeverityError, findings[0].Severity)
+		assert.Equal(t, "db:10", findings[0].Entity)
+	}
+}
+
+func TestLintClusterMaster(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+	assert.Equal(t, NodeID(2), info.SourceNode)
+	assert.Equal(t, info.Source(), info.Node(2))
+
+	linter := &Linter{}
+	linter.Register("cluster-master", checkClusterMaster)
+	assert.Empty(t, linter.Lint(info))
+
+	// node:2 sees node:3 as the cluster master
+	info.Cluster.MasterNode = 3
+	findings := linter.Lint(info)
+	if assert.Len(t, findings, 3) {
+		assert.Equal(t, "node:3", findings[0].Entity)
+		assert.Contains(t, findings[0].Message, "node:2 reports node:3 as cluster master")
+		assert.Equal(t, SeverityWarning, findings[1].Severity)
+		assert.Equal(t, "node:1", findings[2].Entity)
+	}
+
+	// older dumps without a cluster master line still load
+	missing := bytes.Replace(rsOutput, []byte("OK. Cluster master: 1 (10.166.204.139)\n"), nil, 1)
+	info, err = NewClusterInfo("test", bytes.NewReader(missing))
+	if assert.Nil(t, err) && assert.NotNil(t, info.Cluster) {
+		assert.Zero(t, info.Cluster.MasterNode)
+		assert.Equal(t, "OK", info.Cluster.Health)
+		findings = linter.Lint(info)
+		if assert.Len(t, findings, 1) {
+			assert.Equal(t, SeverityWarning, findings[0].Severity)
+		}
+	}
+}
+
+func TestRackAffinity(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+	assert.Empty(t, info.RackAffinity())
+
+	// move the replica of redis:2 into the same rack as its master
+	moved := bytes.Replace(rsOutput, []byte("redis:42 node:28 slave"), []byte("redis:42 node:29 slave"), 1)
+	info, err = NewClusterInfo("test", bytes.NewReader(moved))
+	assert.Nil(t, err)
+
+	report := info.RackAffinity()
+	if assert.Len(t, report, 1) && assert.Len(t, report[0].Violations, 1) {
+		assert.Equal(t, DBID(10), report[0].DBId)
+		assert.Equal(t, AffinityViolation{
+			Master:      2,
+			Replica:     42,
+			MasterNode:  13,
+			ReplicaNode: 29,
+			MasterRack:  "Rack2",
+			ReplicaRack: "Rack2",
+		}, report[0].Violations[0])
+	}
+
+	// without racks, only a master and replica on the same node are violations
+	for _, node := range info.Nodes {
+		node.RackId = ""
+	}
+	assert.Empty(t, info.RackAffinity())
+	info.Shard(42).NodeId = 13
+	info.Reindex()
+	report = info.RackAffinity()
+	if assert.Len(t, report, 1) && assert.Len(t, report[0].Violations, 1) {
+		assert.True(t, report[0].Violations[0].SameNode)
+	}
+}
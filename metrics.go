@@ -0,0 +1,193 @@
+/*
+metrics.go renders cluster information in Prometheus text exposition and OpenMetrics formats
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const metricPrefix = "rladmin_"
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	labels []label
+	value  float64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	info    bool
+	samples []sample
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Prometheus returns the cluster information in Prometheus text exposition format
+func (c *ClusterInfo) Prometheus() (string, error) {
+	out := &strings.Builder{}
+	err := c.WriteMetrics(out, false)
+	return out.String(), err
+}
+
+// OpenMetrics returns the cluster information in OpenMetrics text format
+func (c *ClusterInfo) OpenMetrics() (string, error) {
+	out := &strings.Builder{}
+	err := c.WriteMetrics(out, true)
+	return out.String(), err
+}
+
+// WriteMetrics writes the cluster information as metrics to w. Memory values are
+// converted to bytes. If openMetrics is true, OpenMetrics format is used; otherwise
+// Prometheus text exposition format is written.
+func (c *ClusterInfo) WriteMetrics(w io.Writer, openMetrics bool) error {
+	for _, family := range c.metricFamilies() {
+		if err := family.write(w, openMetrics); err != nil {
+			return err
+		}
+	}
+
+	if openMetrics {
+		_, err := io.WriteString(w, "# EOF\n")
+		return err
+	}
+	return nil
+}
+
+func (c *ClusterInfo) metricFamilies() []*metricFamily {
+	nodeRAMFree := &metricFamily{name: "node_redis_ram_free_bytes", help: "Free RAM available to Redis on the node"}
+	nodeRAMMax := &metricFamily{name: "node_redis_ram_max_bytes", help: "Maximum RAM available to Redis on the node"}
+	nodeProvisionalFree := &metricFamily{name: "node_provisional_ram_free_bytes", help: "Free provisional RAM on the node"}
+	nodeProvisionalMax := &metricFamily{name: "node_provisional_ram_max_bytes", help: "Maximum provisional RAM on the node"}
+	nodeOverbooking := &metricFamily{name: "node_overbooking_depth_bytes", help: "Overbooking depth of the node"}
+	nodeShards := &metricFamily{name: "node_shards", help: "Shards in use on the node"}
+	nodeMaxShards := &metricFamily{name: "node_shards_max", help: "Maximum shards allowed on the node"}
+	nodeInfo := &metricFamily{name: "node", help: "Node role, status and version", info: true}
+	shardUsed := &metricFamily{name: "shard_used_memory_bytes", help: "Memory used by the shard"}
+	shardFrag := &metricFamily{name: "shard_ram_fragmentation_bytes", help: "RAM fragmentation of the shard"}
+	shardInfo := &metricFamily{name: "shard", help: "Shard role and status", info: true}
+	dbInfo := &metricFamily{name: "database", help: "Database configuration and status", info: true}
+	endpointInfo := &metricFamily{name: "endpoint", help: "Endpoint binding and status", info: true}
+
+	for _, node := range c.Nodes {
+		labels := []label{{"key", c.Key}, {"node", node.Id.String()}}
+		nodeRAMFree.add(labels, node.RedisRAM.Free.Bytes())
+		nodeRAMMax.add(labels, node.RedisRAM.Max.Bytes())
+		nodeProvisionalFree.add(labels, node.ProvisionalRAM.Free.Bytes())
+		nodeProvisionalMax.add(labels, node.ProvisionalRAM.Max.Bytes())
+		nodeOverbooking.add(labels, node.OverbookingDepth.Bytes())
+		nodeShards.add(labels, float64(node.ShardUsage.InUse))
+		nodeMaxShards.add(labels, float64(node.ShardUsage.Max))
+		nodeInfo.add(append(labels,
+			label{"role", string(node.Role)},
+			label{"status", string(node.Status)},
+			label{"version", node.Version},
+			label{"rack", node.RackId},
+			label{"address", node.Address.String()},
+		), 1)
+	}
+
+	for _, shard := range c.Shards {
+		labels := []label{{"key", c.Key}, {"db", shard.DBId.String()}, {"shard", shard.Id.String()}, {"node", shard.NodeId.String()}}
+		shardUsed.add(labels, shard.UsedMemory.Bytes())
+		shardFrag.add(labels, shard.RAMFrag.Bytes())
+		shardInfo.add(append(labels,
+			label{"role", string(shard.Role)},
+			label{"status", string(shard.Status)},
+			label{"watchdog_status", string(shard.WatchdogStatus)},
+		), 1)
+	}
+
+	for _, db := range c.Databases {
+		dbInfo.add([]label{
+			{"key", c.Key},
+			{"db", db.Id.String()},
+			{"name", db.Name},
+			{"status", string(db.Status)},
+			{"replication", string(db.Replication)},
+			{"persistence", string(db.Persistence)},
+			{"redis_version", db.RedisVersion},
+		}, 1)
+	}
+
+	for _, endpoint := range c.Endpoints {
+		endpointInfo.add([]label{
+			{"key", c.Key},
+			{"db", endpoint.DBId.String()},
+			{"endpoint", endpoint.Id.String()},
+			{"node", endpoint.NodeId.String()},
+			{"watchdog_status", string(endpoint.WatchdogStatus)},
+		}, 1)
+	}
+
+	families := []*metricFamily{
+		nodeRAMFree, nodeRAMMax, nodeProvisionalFree, nodeProvisionalMax, nodeOverbooking,
+		nodeShards, nodeMaxShards, nodeInfo, shardUsed, shardFrag, shardInfo, dbInfo, endpointInfo,
+	}
+
+	if c.Cluster != nil {
+		families = append(families, &metricFamily{
+			name: "cluster",
+			help: "Cluster master and health",
+			info: true,
+			samples: []sample{{labels: []label{
+				{"key", c.Key},
+				{"master_node", c.Cluster.MasterNode.String()},
+				{"status", c.Cluster.Status},
+				{"health", c.Cluster.Health},
+			}, value: 1}},
+		}, &metricFamily{
+			name:    "cluster_failures_per_minute",
+			help:    "Cluster failures per minute averaged over the last minute",
+			samples: []sample{{labels: []label{{"key", c.Key}}, value: c.Cluster.Failures.Avg1}},
+		})
+	}
+
+	return families
+}
+
+func (m *metricFamily) add(labels []label, value float64) {
+	m.samples = append(m.samples, sample{labels: labels, value: value})
+}
+
+// write outputs the family. Info metrics are written as gauges with an _info
+// suffix in Prometheus format and as the info type in OpenMetrics.
+func (m *metricFamily) write(w io.Writer, openMetrics bool) error {
+	family := metricPrefix + m.name
+	kind := "gauge"
+	sampleName := family
+
+	if m.info {
+		sampleName = family + "_info"
+		if openMetrics {
+			kind = "info"
+		} else {
+			family = sampleName
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", family, m.help, family, kind); err != nil {
+		return err
+	}
+
+	for _, s := range m.samples {
+		labels := make([]string, 0, len(s.labels))
+		for _, l := range s.labels {
+			labels = append(labels, fmt.Sprintf(`%s="%s"`, l.name, labelEscaper.Replace(l.value)))
+		}
+		if _, err := fmt.Fprintf(w, "%s{%s} %s\n", sampleName, strings.Join(labels, ","), strconv.FormatFloat(s.value, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
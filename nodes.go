@@ -5,7 +5,6 @@ Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
 package clusterinfo
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -13,7 +12,6 @@ import (
 	"time"
 
 	"github.com/gocarina/gocsv"
-	"github.com/goslogan/fw"
 )
 
 const (
@@ -36,8 +34,8 @@ type MemoryInfo struct {
 
 type Node struct {
 	Key              string       `columh:"-" json:"key" csv:"key"`
-	Id               string       `json:"nodeId" csv:"nodeId" column:"NODE:ID" `
-	Role             string       `json:"role" csv:"role" column:"ROLE"`
+	Id               NodeID       `json:"nodeId" csv:"nodeId" column:"NODE:ID" `
+	Role             NodeRole     `json:"role" csv:"role" column:"ROLE"`
 	Address          IP           `json:"address" csv:"address" column:"ADDRESS"`
 	ExternalAddress  IP           `json:"externalAddress" csv:"externalAddress" column:"EXTERNAL_ADDRESS"`
 	HostName         string       `json:"hostName" csv:"hostName" column:"HOSTNAME"`
@@ -51,8 +49,9 @@ type Node struct {
 	Version          string       `json:"version" csv:"version" column:"VERSION"`
 	SHA              string       `json:"sha" csv:"sha" column:"SHA"`
 	RackId           string       `json:"rackId" csv:"rackId" column:"RACK-ID"`
-	Status           string       `json:"status" csv:"status" column:"STATUS"`
+	Status           NodeStatus   `json:"status" csv:"status" column:"STATUS"`
 	Quorum           bool         `json:"quorum" csv:"quorum" column:"-"`
+	IsLocal          bool         `json:"isLocal" csv:"isLocal" column:"-"`
 	TimeStamp        time.Time    `json:"timeStamp" csv:"timeStamp" column:"-"`
 	parent           *ClusterInfo `csv:"-" column:"-"`
 }
@@ -60,18 +59,20 @@ type Node struct {
 type Nodes []*Node
 
 func (c *Chunks) ParseNodes(parent *ClusterInfo) (Nodes, error) {
+	nodes, _, err := c.parseNodes(parent, false)
+	return nodes, err
+}
 
-	nodes := []*Node{}
-
-	decoder := fw.NewDecoder(bytes.NewReader(c.Nodes))
-	decoder.IgnoreEmptyRecords = true
-
-	err := decoder.Decode(&nodes)
+func (c *Chunks) parseNodes(parent *ClusterInfo, lenient bool) (Nodes, ParseIssues, error) {
 
+	nodes, issues, err := decodeChunk[Node](c, ChunkNodes, c.Nodes, lenient)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	// rladmin marks the node it was run on with a "*" which the id parser drops
+	parent.SourceNode = sourceNodeId(c.Nodes)
+
 	for _, node := range nodes {
 		node.parent = parent
 		node.Key = parent.Key
@@ -79,29 +80,49 @@ func (c *Chunks) ParseNodes(parent *ClusterInfo) (Nodes, error) {
 		if node.ShardUsage.Max == 0 {
 			node.Quorum = true
 		}
-		// strip of the "*" prefix from the node that ran the rladmin status
-		node.Id = strings.TrimPrefix(node.Id, "*")
+		node.IsLocal = node.Id != 0 && node.Id == parent.SourceNode
+	}
+
+	return nodes, issues, nil
+}
+
+// sourceNodeId returns the id of the node row marked with a "*" or zero if there isn't one
+func sourceNodeId(data []byte) NodeID {
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines[1:] {
+		if field, _, _ := strings.Cut(strings.TrimSpace(line), " "); strings.HasPrefix(field, "*") {
+			if id, err := ParseNodeID(field); err == nil {
+				return id
+			}
+		}
 	}
+	return 0
+}
 
-	return nodes, nil
+// Source returns the node rladmin was run on, or nil if it isn't known
+func (c *ClusterInfo) Source() *Node {
+	if c.SourceNode == 0 {
+		return nil
+	}
+	return c.Node(c.SourceNode)
 }
 
 func (m *MemoryInfo) UnmarshalText(input []byte) error {
 	if parts := strings.Split(string(input), "/"); len(parts) == 2 {
 		f, err := parseMemory(parts[0])
 		if err != nil {
-			return fmt.Errorf(errorString, "memory info", parts[0], err)
+			return fmt.Errorf(errorString, parts[0], "memory info", err)
 		} else {
 			m.Free = RAMFloat(f)
 		}
 		f, err = parseMemory(parts[1])
 		if err != nil {
-			return fmt.Errorf(errorString, "memory info", parts[1], err)
+			return fmt.Errorf(errorString, parts[1], "memory info", err)
 		} else {
 			m.Max = RAMFloat(f)
 		}
 	} else {
-		return fmt.Errorf("unable to split %s into parts for memory info", input)
+		return fmt.Errorf("unable to split '%s' into parts for memory info", input)
 	}
 
 	return nil
@@ -115,23 +136,31 @@ func (i *IP) UnmarshalText(input []byte) error {
 
 	i.IP = net.ParseIP(string(input))
 	if i.IP == nil {
-		return fmt.Errorf(errorString, "address", input, nil)
+		return fmt.Errorf("unable to parse '%s' as address", input)
 	} else {
 		return nil
 	}
 }
 
+// String returns the address or an empty string if there isn't one
+func (i IP) String() string {
+	if i.IP == nil {
+		return ""
+	}
+	return i.IP.String()
+}
+
 func (s *ShardInfo) UnmarshalCSV(input string) error {
 	var err error
 	if parts := strings.Split(input, "/"); len(parts) == 2 {
 		if s.InUse, err = toUint16(parts[0]); err != nil {
-			return fmt.Errorf(errorString, "number of shards", parts[0], err)
+			return fmt.Errorf(errorString, parts[0], "number of shards", err)
 		}
 		if s.Max, err = toUint16(parts[1]); err != nil {
-			return fmt.Errorf(errorString, "maximum number of shards", parts[1], err)
+			return fmt.Errorf(errorString, parts[1], "maximum number of shards", err)
 		}
 	} else {
-		return fmt.Errorf("unable to split %s into parts for shard counts", input)
+		return fmt.Errorf("unable to split '%s' into parts for shard counts", input)
 	}
 
 	return nil
@@ -0,0 +1,866 @@
+/*
+query.go provides a small query language over the nodes, databases, shards and endpoints
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A query selects one kind of entity and optionally filters, groups, orders and limits it
+//
+//	source [where condition] [group by field] [aggregate, ...] [order by column [asc|desc]] [limit n]
+//
+// The source is nodes, databases, shards or endpoints. Fields are named by their JSON names,
+// with a dot for nested values such as redisRAM.free. Conditions compare fields with values or
+// other fields using ==, !=, <, <=, >, >= and contains, combined with and, or, not and
+// parentheses. Values are quoted strings, numbers, true, false or memory sizes such as 5GB or
+// 512MB, which can only be compared with memory fields. Strings are parsed as the field they
+// are compared with, so node == "node:3" and node == 3 are the same, and string comparisons
+// ignore case. The aggregates are count(), sum(field), avg(field), min(field) and max(field).
+// For example
+//
+//	shards where role == "master" and usedMemory > 5GB group by node sum(usedMemory)
+//
+// A Query must be created by ParseQuery.
+type Query struct {
+	source     querySource
+	where      queryExpr
+	groupBy    *queryField
+	aggregates []queryAggregate
+	orderBy    string
+	descending bool
+	limit      int
+}
+
+// QueryResult is a table of values. Without aggregates, there is a row for each matching
+// entity and a column for each field. Otherwise, there is a row for each group.
+type QueryResult struct {
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+type querySource struct {
+	name   string
+	entity reflect.Type
+	items  func(*ClusterInfo) []reflect.Value
+}
+
+var querySources = map[string]querySource{
+	"nodes":     {"nodes", reflect.TypeFor[Node](), func(c *ClusterInfo) []reflect.Value { return queryItems(c.Nodes) }},
+	"databases": {"databases", reflect.TypeFor[Database](), func(c *ClusterInfo) []reflect.Value { return queryItems(c.Databases) }},
+	"shards":    {"shards", reflect.TypeFor[Shard](), func(c *ClusterInfo) []reflect.Value { return queryItems(c.Shards) }},
+	"endpoints": {"endpoints", reflect.TypeFor[Endpoint](), func(c *ClusterInfo) []reflect.Value { return queryItems(c.Endpoints) }},
+}
+
+var queryAggregates = []string{"count", "sum", "avg", "min", "max"}
+
+// queryField is a field of an entity found by its JSON name
+type queryField struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+type queryAggregate struct {
+	function string
+	field    *queryField
+}
+
+// queryValue is a value reduced to a number, string or bool for comparison
+type queryValue struct {
+	kind reflect.Kind // Float64, String or Bool
+	num  float64
+	str  string
+	b    bool
+}
+
+type queryExpr interface {
+	eval(item reflect.Value) (bool, error)
+}
+
+type queryAnd struct{ left, right queryExpr }
+type queryOr struct{ left, right queryExpr }
+type queryNot struct{ expr queryExpr }
+
+// queryOperand is either a field or a literal value. memory is true for a literal memory
+// size such as 5GB.
+type queryOperand struct {
+	field  *queryField
+	value  queryValue
+	memory bool
+}
+
+type queryCompare struct {
+	left, right queryOperand
+	op          string
+}
+
+// Query parses and runs a query against the cluster information
+func (c *ClusterInfo) Query(text string) (*QueryResult, error) {
+	q, err := ParseQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	return q.Run(c)
+}
+
+// ParseQuery parses a query so it can be run against any cluster information
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	return p.parse()
+}
+
+// Run returns the result of the query against the cluster information
+func (q *Query) Run(c *ClusterInfo) (*QueryResult, error) {
+	if q.source.items == nil {
+		return nil, fmt.Errorf("rlatool - query: queries must be created by ParseQuery")
+	}
+
+	items := []reflect.Value{}
+	for _, item := range q.source.items(c) {
+		if q.where != nil {
+			matched, err := q.where.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				continue
+			}
+		}
+		items = append(items, item)
+	}
+
+	var result *QueryResult
+	if q.groupBy == nil && len(q.aggregates) == 0 {
+		result = q.rows(items)
+	} else {
+		result = q.groups(items)
+	}
+
+	if q.orderBy != "" {
+		column := slices.Index(result.Columns, q.orderBy)
+		if column < 0 {
+			return nil, fmt.Errorf("rlatool - query: unable to order by '%s' as it isn't a column of the result", q.orderBy)
+		}
+		slices.SortStableFunc(result.Rows, func(a, b []any) int {
+			order := compareQueryValues(toQueryValue(reflect.ValueOf(a[column])), toQueryValue(reflect.ValueOf(b[column])))
+			if q.descending {
+				return -order
+			}
+			return order
+		})
+	}
+
+	if q.limit > 0 && len(result.Rows) > q.limit {
+		result.Rows = result.Rows[:q.limit]
+	}
+	return result, nil
+}
+
+// rows returns a row with every field for each item
+func (q *Query) rows(items []reflect.Value) *QueryResult {
+	fields := queryLeaves(q.source.entity, "", nil)
+	result := &QueryResult{Columns: make([]string, len(fields)), Rows: make([][]any, len(items))}
+	for n, field := range fields {
+		result.Columns[n] = field.name
+	}
+	for n, item := range items {
+		row := make([]any, len(fields))
+		for i, field := range fields {
+			row[i] = item.FieldByIndex(field.index).Interface()
+		}
+		result.Rows[n] = row
+	}
+	return result
+}
+
+// groups returns a row of aggregates for each group, in group order. Without a group by
+// field, everything is in one group.
+func (q *Query) groups(items []reflect.Value) *QueryResult {
+	aggregates := q.aggregates
+	if len(aggregates) == 0 {
+		aggregates = []queryAggregate{{function: "count"}}
+	}
+
+	result := &QueryResult{Columns: []string{}, Rows: [][]any{}}
+	if q.groupBy != nil {
+		result.Columns = append(result.Columns, q.groupBy.name)
+	}
+	for _, aggregate := range aggregates {
+		result.Columns = append(result.Columns, aggregate.String())
+	}
+
+	keys := []any{}
+	groups := map[string][]reflect.Value{}
+	if q.groupBy == nil {
+		keys = append(keys, nil)
+		groups[""] = items
+	} else {
+		for _, item := range items {
+			key := item.FieldByIndex(q.groupBy.index)
+			text := queryText(key)
+			if _, ok := groups[text]; !ok {
+				keys = append(keys, key.Interface())
+			}
+			groups[text] = append(groups[text], item)
+		}
+		slices.SortStableFunc(keys, func(a, b any) int {
+			return compareQueryValues(toQueryValue(reflect.ValueOf(a)), toQueryValue(reflect.ValueOf(b)))
+		})
+	}
+
+	for _, key := range keys {
+		row := []any{}
+		group := groups[""]
+		if q.groupBy != nil {
+			row = append(row, key)
+			group = groups[queryText(reflect.ValueOf(key))]
+		}
+		for _, aggregate := range aggregates {
+			row = append(row, aggregate.apply(group))
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result
+}
+
+func (a queryAggregate) String() string {
+	if a.field == nil {
+		return a.function
+	}
+	return a.function + "(" + a.field.name + ")"
+}
+
+// apply calculates the aggregate. Memory values keep their type, other values are float64.
+func (a queryAggregate) apply(items []reflect.Value) any {
+	if a.function == "count" {
+		return len(items)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	values := make([]float64, len(items))
+	for n, item := range items {
+		values[n] = toQueryValue(item.FieldByIndex(a.field.index)).num
+	}
+
+	var value float64
+	switch a.function {
+	case "sum", "avg":
+		for _, v := range values {
+			value += v
+		}
+		if a.function == "avg" {
+			value /= float64(len(values))
+		}
+	case "min":
+		value = slices.Min(values)
+	case "max":
+		value = slices.Max(values)
+	}
+
+	if a.field.typ.Kind() == reflect.Float32 || a.field.typ.Kind() == reflect.Float64 {
+		return reflect.ValueOf(value).Convert(a.field.typ).Interface()
+	}
+	return value
+}
+
+func (e *queryAnd) eval(item reflect.Value) (bool, error) {
+	left, err := e.left.eval(item)
+	if err != nil || !left {
+		return false, err
+	}
+	return e.right.eval(item)
+}
+
+func (e *queryOr) eval(item reflect.Value) (bool, error) {
+	left, err := e.left.eval(item)
+	if err != nil || left {
+		return left, err
+	}
+	return e.right.eval(item)
+}
+
+func (e *queryNot) eval(item reflect.Value) (bool, error) {
+	result, err := e.expr.eval(item)
+	return !result, err
+}
+
+func (e *queryCompare) eval(item reflect.Value) (bool, error) {
+	left, right := e.left.resolve(item), e.right.resolve(item)
+
+	if e.op == "contains" {
+		if left.kind != reflect.String || right.kind != reflect.String {
+			return false, fmt.Errorf("rlatool - query: contains needs string values")
+		}
+		return strings.Contains(strings.ToLower(left.str), strings.ToLower(right.str)), nil
+	}
+
+	if left.kind != right.kind {
+		return false, fmt.Errorf("rlatool - query: unable to compare %s with %s", left, right)
+	}
+	if left.kind == reflect.Bool && e.op != "==" && e.op != "!=" {
+		return false, fmt.Errorf("rlatool - query: unable to use %s with true or false", e.op)
+	}
+
+	order := compareQueryValues(left, right)
+	switch e.op {
+	case "==":
+		return order == 0, nil
+	case "!=":
+		return order != 0, nil
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+func (o queryOperand) resolve(item reflect.Value) queryValue {
+	if o.field != nil {
+		return toQueryValue(item.FieldByIndex(o.field.index))
+	}
+	return o.value
+}
+
+// toQueryValue reduces a value to a number, string or bool
+func toQueryValue(v reflect.Value) queryValue {
+	if !v.IsValid() {
+		return queryValue{kind: reflect.String}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return queryValue{kind: reflect.Bool, b: v.Bool()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return queryValue{kind: reflect.Float64, num: float64(v.Int())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return queryValue{kind: reflect.Float64, num: float64(v.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return queryValue{kind: reflect.Float64, num: v.Float()}
+	}
+	return queryValue{kind: reflect.String, str: queryText(v)}
+}
+
+// queryText returns the text form of a value, as used in the JSON output where possible
+func queryText(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if text, err := marshaler.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(math.Round(v.Float()*1e5)/1e5, 'f', -1, 64)
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for n := range parts {
+			parts[n] = queryText(v.Index(n))
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// compareQueryValues orders values of the same kind. Strings are compared ignoring case.
+func compareQueryValues(a, b queryValue) int {
+	switch {
+	case a.kind != b.kind:
+		return strings.Compare(a.kind.String(), b.kind.String())
+	case a.kind == reflect.Float64:
+		if a.num < b.num {
+			return -1
+		} else if a.num > b.num {
+			return 1
+		}
+		return 0
+	case a.kind == reflect.Bool:
+		if a.b == b.b {
+			return 0
+		} else if b.b {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(strings.ToLower(a.str), strings.ToLower(b.str))
+}
+
+func (v queryValue) String() string {
+	switch v.kind {
+	case reflect.Float64:
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.b)
+	}
+	return strconv.Quote(v.str)
+}
+
+// queryItems returns the entities as struct values
+func queryItems[T any](list []*T) []reflect.Value {
+	items := make([]reflect.Value, len(list))
+	for n, item := range list {
+		items[n] = reflect.ValueOf(item).Elem()
+	}
+	return items
+}
+
+// queryJSONName returns the name encoding/json uses for a field or "" if it is left out
+func queryJSONName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	} else if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// isQueryLeaf returns true for values which aren't broken down into their fields
+func isQueryLeaf(t reflect.Type) bool {
+	return t.Kind() != reflect.Struct || t.Implements(reflect.TypeFor[encoding.TextMarshaler]()) || t == reflect.TypeFor[time.Time]()
+}
+
+// queryLeaves returns every field of an entity which has a single value, with nested fields
+// named by their path
+func queryLeaves(t reflect.Type, prefix string, index []int) []*queryField {
+	fields := []*queryField{}
+	for _, field := range reflect.VisibleFields(t) {
+		name := queryJSONName(field)
+		if field.Anonymous || name == "" || len(field.Index) > 1 {
+			continue
+		}
+		path := append(slices.Clone(index), field.Index...)
+		if isQueryLeaf(field.Type) {
+			fields = append(fields, &queryField{name: prefix + name, index: path, typ: field.Type})
+		} else {
+			fields = append(fields, queryLeaves(field.Type, prefix+name+".", path)...)
+		}
+	}
+	return fields
+}
+
+// findQueryField finds a field by its path, ignoring case
+func findQueryField(t reflect.Type, path string) (*queryField, bool) {
+	for _, field := range queryLeaves(t, "", nil) {
+		if strings.EqualFold(field.name, path) {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+type queryTokenKind int
+
+const (
+	tokenEnd queryTokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenMemory
+	tokenOperator
+	tokenPunct
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int
+}
+
+// lexQuery splits a query into tokens
+func lexQuery(text string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	runes := []rune(text)
+
+	for pos := 0; pos < len(runes); {
+		r := runes[pos]
+		start := pos
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+			continue
+		case r == '"' || r == '\'':
+			value := &strings.Builder{}
+			for pos++; pos < len(runes) && runes[pos] != r; pos++ {
+				if runes[pos] == '\\' && pos+1 < len(runes) {
+					pos++
+				}
+				value.WriteRune(runes[pos])
+			}
+			if pos >= len(runes) {
+				return nil, queryError(start, "unterminated string")
+			}
+			pos++
+			tokens = append(tokens, queryToken{tokenString, value.String(), start})
+		case unicode.IsDigit(r) || (r == '-' && pos+1 < len(runes) && unicode.IsDigit(runes[pos+1])):
+			for pos++; pos < len(runes) && (unicode.IsDigit(runes[pos]) || runes[pos] == '.'); pos++ {
+			}
+			kind := tokenNumber
+			if pos < len(runes) && unicode.IsLetter(runes[pos]) {
+				kind = tokenMemory
+				for ; pos < len(runes) && unicode.IsLetter(runes[pos]); pos++ {
+				}
+			}
+			tokens = append(tokens, queryToken{kind, string(runes[start:pos]), start})
+		case unicode.IsLetter(r) || r == '_':
+			for pos++; pos < len(runes) && (unicode.IsLetter(runes[pos]) || unicode.IsDigit(runes[pos]) || runes[pos] == '_' || runes[pos] == '.'); pos++ {
+			}
+			tokens = append(tokens, queryToken{tokenIdent, string(runes[start:pos]), start})
+		case strings.ContainsRune("=!<>", r):
+			pos++
+			if pos < len(runes) && runes[pos] == '=' {
+				pos++
+			}
+			op := string(runes[start:pos])
+			if op == "=" || op == "!" {
+				return nil, queryError(start, fmt.Sprintf("unknown operator '%s'", op))
+			}
+			tokens = append(tokens, queryToken{tokenOperator, op, start})
+		case strings.ContainsRune("(),", r):
+			pos++
+			tokens = append(tokens, queryToken{tokenPunct, string(r), start})
+		default:
+			return nil, queryError(start, fmt.Sprintf("unexpected '%c'", r))
+		}
+	}
+
+	return append(tokens, queryToken{tokenEnd, "", len(runes)}), nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	query  *Query
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEnd {
+		p.pos++
+	}
+	return token
+}
+
+// keyword consumes the next token if it is the keyword
+func (p *queryParser) keyword(word string) bool {
+	if token := p.peek(); token.kind == tokenIdent && strings.EqualFold(token.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) punct(text string) bool {
+	if token := p.peek(); token.kind == tokenPunct && token.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(text string) error {
+	if !p.punct(text) && !p.keyword(text) {
+		return queryError(p.peek().pos, fmt.Sprintf("expected '%s'", text))
+	}
+	return nil
+}
+
+func (p *queryParser) parse() (*Query, error) {
+	token := p.next()
+	source, ok := querySources[strings.ToLower(token.text)]
+	if token.kind != tokenIdent || !ok {
+		return nil, queryError(token.pos, "expected nodes, databases, shards or endpoints")
+	}
+	p.query = &Query{source: source}
+
+	var err error
+	if p.keyword("where") {
+		if p.query.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.keyword("group") {
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		if p.query.groupBy, err = p.parseField(); err != nil {
+			return nil, err
+		}
+	}
+
+	for p.isAggregate() {
+		aggregate, err := p.parseAggregate()
+		if err != nil {
+			return nil, err
+		}
+		p.query.aggregates = append(p.query.aggregates, aggregate)
+		if !p.punct(",") {
+			break
+		}
+	}
+
+	if p.keyword("order") {
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		if p.isAggregate() {
+			aggregate, err := p.parseAggregate()
+			if err != nil {
+				return nil, err
+			}
+			p.query.orderBy = aggregate.String()
+		} else if token := p.next(); token.kind != tokenIdent {
+			return nil, queryError(token.pos, "expected a column")
+		} else if field, ok := findQueryField(p.query.source.entity, token.text); ok {
+			p.query.orderBy = field.name
+		} else {
+			p.query.orderBy = token.text
+		}
+		if p.keyword("desc") {
+			p.query.descending = true
+		} else {
+			p.keyword("asc")
+		}
+	}
+
+	if p.keyword("limit") {
+		token := p.next()
+		limit, err := strconv.Atoi(token.text)
+		if token.kind != tokenNumber || err != nil || limit < 1 {
+			return nil, queryError(token.pos, "expected a limit greater than zero")
+		}
+		p.query.limit = limit
+	}
+
+	if token := p.peek(); token.kind != tokenEnd {
+		return nil, queryError(token.pos, fmt.Sprintf("unexpected '%s'", token.text))
+	}
+	return p.query, nil
+}
+
+func (p *queryParser) isAggregate() bool {
+	token := p.peek()
+	return token.kind == tokenIdent && slices.Contains(queryAggregates, strings.ToLower(token.text)) &&
+		p.tokens[p.pos+1].kind == tokenPunct && p.tokens[p.pos+1].text == "("
+}
+
+func (p *queryParser) parseAggregate() (queryAggregate, error) {
+	aggregate := queryAggregate{function: strings.ToLower(p.next().text)}
+	p.next()
+
+	if aggregate.function != "count" {
+		field, err := p.parseField()
+		if err != nil {
+			return aggregate, err
+		}
+		if toQueryValue(reflect.New(field.typ).Elem()).kind != reflect.Float64 {
+			return aggregate, queryError(p.peek().pos, fmt.Sprintf("%s needs a numeric field", aggregate.function))
+		}
+		aggregate.field = field
+	}
+	return aggregate, p.expect(")")
+}
+
+func (p *queryParser) parseField() (*queryField, error) {
+	token := p.next()
+	if token.kind != tokenIdent {
+		return nil, queryError(token.pos, "expected a field")
+	}
+	field, ok := findQueryField(p.query.source.entity, token.text)
+	if !ok {
+		return nil, queryError(token.pos, fmt.Sprintf("unknown field '%s' for %s", token.text, p.query.source.name))
+	}
+	return field, nil
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.keyword("or") {
+		var right queryExpr
+		if right, err = p.parseAnd(); err == nil {
+			left = &queryOr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	left, err := p.parseNot()
+	for err == nil && p.keyword("and") {
+		var right queryExpr
+		if right, err = p.parseNot(); err == nil {
+			left = &queryAnd{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) parseNot() (queryExpr, error) {
+	if p.keyword("not") {
+		expr, err := p.parseNot()
+		return &queryNot{expr}, err
+	}
+	if p.punct("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	}
+	return p.parseCompare()
+}
+
+func (p *queryParser) parseCompare() (queryExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	token := p.next()
+	op := token.text
+	if token.kind == tokenIdent && strings.EqualFold(op, "contains") {
+		op = "contains"
+	} else if token.kind != tokenOperator {
+		return nil, queryError(token.pos, "expected a comparison")
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	// memory sizes can only be compared with memory fields
+	for _, pair := range [][2]queryOperand{{left, right}, {right, left}} {
+		if pair[0].memory && (pair[1].field == nil || pair[1].field.typ != reflect.TypeFor[RAMFloat]()) {
+			return nil, queryError(token.pos, "memory sizes can only be compared with memory fields")
+		}
+	}
+
+	// literals are parsed as the field they are compared with
+	if left.field != nil && right.field == nil {
+		right.value, err = coerceQueryValue(right.value, left.field, token.pos)
+	} else if right.field != nil && left.field == nil {
+		left.value, err = coerceQueryValue(left.value, right.field, token.pos)
+	}
+	return &queryCompare{left: left, right: right, op: op}, err
+}
+
+func (p *queryParser) parseOperand() (queryOperand, error) {
+	token := p.peek()
+	switch token.kind {
+	case tokenString:
+		p.next()
+		return queryOperand{value: queryValue{kind: reflect.String, str: token.text}}, nil
+	case tokenNumber:
+		p.next()
+		v, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return queryOperand{}, queryError(token.pos, err.Error())
+		}
+		return queryOperand{value: queryValue{kind: reflect.Float64, num: v}}, nil
+	case tokenMemory:
+		p.next()
+		v, err := parseMemory(token.text)
+		if err != nil {
+			return queryOperand{}, queryError(token.pos, fmt.Sprintf("unable to parse '%s' as memory", token.text))
+		}
+		return queryOperand{value: queryValue{kind: reflect.Float64, num: float64(v)}, memory: true}, nil
+	case tokenIdent:
+		if p.keyword("true") || p.keyword("false") {
+			return queryOperand{value: queryValue{kind: reflect.Bool, b: strings.EqualFold(token.text, "true")}}, nil
+		}
+		field, err := p.parseField()
+		return queryOperand{field: field}, err
+	}
+	return queryOperand{}, queryError(token.pos, "expected a field or value")
+}
+
+// coerceQueryValue parses a string as the type of the field when the field has a text form,
+// so ids, roles, statuses and memory sizes can be given as rladmin shows them
+func coerceQueryValue(value queryValue, field *queryField, pos int) (queryValue, error) {
+	if value.kind != reflect.String {
+		return value, nil
+	}
+	parsed := reflect.New(field.typ)
+	unmarshaler, ok := parsed.Interface().(encoding.TextUnmarshaler)
+	if !ok {
+		return value, nil
+	}
+	if err := unmarshaler.UnmarshalText([]byte(value.str)); err != nil {
+		return value, queryError(pos, fmt.Sprintf("unable to parse '%s' as %s: %s", value.str, field.name, err))
+	}
+	return toQueryValue(parsed.Elem()), nil
+}
+
+func queryError(pos int, message string) error {
+	return fmt.Errorf("rlatool - query: %s at position %d", message, pos+1)
+}
+
+func (r *QueryResult) JSON() (string, error) {
+	objects := make([]map[string]any, len(r.Rows))
+	for n, row := range r.Rows {
+		objects[n] = map[string]any{}
+		for i, column := range r.Columns {
+			objects[n][column] = row[i]
+		}
+	}
+	if out, err := json.Marshal(objects); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (r *QueryResult) CSV(skipHeaders bool) (string, error) {
+	out := &strings.Builder{}
+	writer := csv.NewWriter(out)
+	if !skipHeaders {
+		if err := writer.Write(r.Columns); err != nil {
+			return "", err
+		}
+	}
+	for _, row := range r.Rows {
+		record := make([]string, len(row))
+		for n, value := range row {
+			record[n] = queryText(reflect.ValueOf(value))
+		}
+		if err := writer.Write(record); err != nil {
+			return "", err
+		}
+	}
+	writer.Flush()
+	return out.String(), writer.Error()
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryGroup(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	expected := map[NodeID]RAMFloat{}
+	for _, shard := range info.Shards {
+		if shard.Role == ShardRoleMaster && shard.UsedMemory > 5 {
+			expected[shard.NodeId] += shard.UsedMemory
+		}
+	}
+
+	result, err := info.Query(`shards where role == "master" and usedMemory > 5GB group by node sum(usedMemory)`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"node", "sum(usedMemory)"}, result.Columns)
+		assert.Len(t, result.Rows, len(expected))
+		for _, row := range result.Rows {
+			assert.InDelta(t, float64(expected[row[0].(NodeID)]), float64(row[1].(RAMFloat)), 0.0001)
+		}
+		assert.Equal(t, NodeID(2), result.Rows[0][0])
+	}
+
+	result, err = info.Query(`shards sum(usedMemory), count() order by count desc`)
+	if assert.Nil(t, err) && assert.Len(t, result.Rows, 1) {
+		assert.Equal(t, len(info.Shards), result.Rows[0][1])
+	}
+
+	result, err = info.Query(`shards group by node order by count() desc limit 1`)
+	if assert.Nil(t, err) && assert.Len(t, result.Rows, 1) {
+		assert.Equal(t, []string{"node", "count"}, result.Columns)
+		assert.Equal(t, NodeID(1), result.Rows[0][0])
+		assert.Equal(t, 94, result.Rows[0][1])
+	}
+}
+
+func TestQueryWhere(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	byId, err := info.Query(`shards where node == "node:7" or NODE == 3 order by usedMemory desc`)
+	assert.Nil(t, err)
+	byNumber, err := info.Query(`shards where not (node != 7 and node != "3")`)
+	assert.Nil(t, err)
+	assert.Len(t, byId.Rows, len(info.Node(7).Shards())+len(info.Node(3).Shards()))
+	assert.ElementsMatch(t, byId.Rows, byNumber.Rows)
+	usedMemory := 7
+	assert.Equal(t, "usedMemory", byId.Columns[usedMemory])
+	assert.GreaterOrEqual(t, byId.Rows[0][usedMemory], byId.Rows[1][usedMemory])
+
+	result, err := info.Query(`nodes where redisRAM.free < 20GB and status == "ok" and quorum == true`)
+	if assert.Nil(t, err) && assert.Len(t, result.Rows, 1) {
+		assert.Equal(t, NodeID(14), result.Rows[0][1])
+	}
+
+	result, err = info.Query(`endpoints where name contains 'SUDAN' and ssl == false`)
+	if assert.Nil(t, err) {
+		assert.Len(t, result.Rows, 2)
+		out, err := result.CSV(false)
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		assert.Equal(t, "key,id,dbId,name,node,role,ssl,watchdogStatus,timeStamp", lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "test,endpoint:10567021:1,db:10567021,sudan-02,node:1,single,false,OK,"))
+	}
+
+	result, err = info.Query(`shards where usedMemory >= "512MB" and usedMemory < 0.75 limit 3`)
+	if assert.Nil(t, err) {
+		assert.Len(t, result.Rows, 3)
+		out, err := result.JSON()
+		assert.Nil(t, err)
+		assert.Contains(t, out, `"node":"node:`)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		`things`,
+		`shards where colour == "red"`,
+		`shards where usedMemory > "lots"`,
+		`shards where (role == "master"`,
+		`shards group by node sum(role)`,
+		`shards where role = "master"`,
+		`shards where name == "unterminated`,
+		`shards limit 0`,
+		`shards where role == "master" extra`,
+		`nodes where cores > 5GB`,
+		`shards where 1GB < 2GB`,
+		`shards where usedMemory == ""`,
+	} {
+		_, err := ParseQuery(query)
+		assert.NotNil(t, err, query)
+	}
+
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+	_, err = info.Query(`shards where role > 5`)
+	assert.NotNil(t, err)
+	_, err = info.Query(`shards order by total`)
+	assert.NotNil(t, err)
+	_, err = (&Query{}).Run(info)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,85 @@
+/*
+rack.go verifies rack (zone) anti-affinity for replicated databases
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding/json"
+)
+
+// AffinityViolation is a master shard and replica which are not separated by rack
+type AffinityViolation struct {
+	Master      ShardID `json:"master"`
+	Replica     ShardID `json:"replica"`
+	MasterNode  NodeID  `json:"masterNode"`
+	ReplicaNode NodeID  `json:"replicaNode"`
+	MasterRack  string  `json:"masterRack"`
+	ReplicaRack string  `json:"replicaRack"`
+	SameNode    bool    `json:"sameNode"`
+}
+
+// DatabaseAffinity lists the anti-affinity violations for a database
+type DatabaseAffinity struct {
+	DBId       DBID                `json:"dbId"`
+	Name       string              `json:"name"`
+	Violations []AffinityViolation `json:"violations"`
+}
+
+type AffinityReport []*DatabaseAffinity
+
+// RackAffinity checks that the replica of every master shard in a replicated
+// database is on a different node in a different rack. Racks are only compared
+// when both nodes have one, so clusters which aren't rack aware only report
+// pairs on the same node. Only databases with violations are returned.
+func (c *ClusterInfo) RackAffinity() AffinityReport {
+	report := AffinityReport{}
+
+	racks := map[NodeID]string{}
+	for _, node := range c.Nodes {
+		racks[node.Id] = node.RackId
+	}
+
+	for _, db := range c.Databases {
+		if !db.Replication.Enabled() {
+			continue
+		}
+
+		affinity := &DatabaseAffinity{DBId: db.Id, Name: db.Name, Violations: []AffinityViolation{}}
+		for _, pair := range db.Shards().Pairs() {
+			if pair.Replica == nil {
+				continue
+			}
+
+			masterRack := racks[pair.Master.NodeId]
+			replicaRack := racks[pair.Replica.NodeId]
+			sameNode := pair.Master.NodeId == pair.Replica.NodeId
+			sameRack := masterRack != "" && masterRack == replicaRack
+			if sameNode || sameRack {
+				affinity.Violations = append(affinity.Violations, AffinityViolation{
+					Master:      pair.Master.Id,
+					Replica:     pair.Replica.Id,
+					MasterNode:  pair.Master.NodeId,
+					ReplicaNode: pair.Replica.NodeId,
+					MasterRack:  masterRack,
+					ReplicaRack: replicaRack,
+					SameNode:    sameNode,
+				})
+			}
+		}
+
+		if len(affinity.Violations) > 0 {
+			report = append(report, affinity)
+		}
+	}
+
+	return report
+}
+
+func (r AffinityReport) JSON() (string, error) {
+	if out, err := json.Marshal(r); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
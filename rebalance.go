@@ -0,0 +1,251 @@
+/*
+rebalance.go plans shard migrations to even out memory and shard roles across nodes
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// rebalanceThreshold is the smallest improvement in balance worth a migration
+const rebalanceThreshold = 0.001
+
+// NodeLoad is the predicted state of a node during a rebalance
+type NodeLoad struct {
+	Id         NodeID   `json:"id"`
+	RackId     string   `json:"rackId"`
+	UsedMemory RAMFloat `json:"usedMemory"`
+	FreeRAM    RAMFloat `json:"freeRAM"`
+	MaxRAM     RAMFloat `json:"maxRAM"`
+	Masters    uint16   `json:"masters"`
+	Replicas   uint16   `json:"replicas"`
+	Shards     uint16   `json:"shards"`
+	MaxShards  uint16   `json:"maxShards"`
+}
+
+// MigrationStep is a single shard migration and the predicted state of every node after it
+type MigrationStep struct {
+	Step    int         `json:"step"`
+	Shard   ShardID     `json:"shard"`
+	DBId    DBID        `json:"dbId"`
+	Role    ShardRole   `json:"role"`
+	From    NodeID      `json:"from"`
+	To      NodeID      `json:"to"`
+	Size    RAMFloat    `json:"size"`
+	Command string      `json:"command"`
+	Nodes   []*NodeLoad `json:"nodes"`
+}
+
+// RebalancePlan is a sequence of migrations with the node state before and after them
+type RebalancePlan struct {
+	Key    string           `json:"key"`
+	Before []*NodeLoad      `json:"before"`
+	Steps  []*MigrationStep `json:"steps"`
+	After  []*NodeLoad      `json:"after"`
+}
+
+// PlanRebalance proposes up to maxSteps shard migrations which even out memory utilisation and master
+// and replica counts across the nodes which can hold shards. A shard is only moved to a node with
+// a free shard and enough free RAM for it, and never to the node or rack of its master or replica.
+// Planning stops early if no migration improves the balance. Nodes which are not OK are ignored.
+func (c *ClusterInfo) PlanRebalance(maxSteps int) *RebalancePlan {
+	plan := &RebalancePlan{Key: c.Key, Steps: []*MigrationStep{}}
+
+	racks := map[NodeID]string{}
+	loads := map[NodeID]*NodeLoad{}
+	order := []*NodeLoad{}
+	for _, node := range c.Nodes {
+		racks[node.Id] = node.RackId
+		if node.Status.IsHealthy() && node.ShardUsage.Max > 0 {
+			load := &NodeLoad{Id: node.Id, RackId: node.RackId, FreeRAM: node.RedisRAM.Free, MaxRAM: node.RedisRAM.Max, MaxShards: node.ShardUsage.Max}
+			loads[node.Id] = load
+			order = append(order, load)
+		}
+	}
+
+	location := map[*Shard]NodeID{}
+	partners := map[*Shard]*Shard{}
+	for _, shard := range c.Shards {
+		location[shard] = shard.NodeId
+		if load, ok := loads[shard.NodeId]; ok {
+			load.add(shard, 1)
+		}
+	}
+	for _, db := range c.Databases {
+		for _, pair := range db.Shards().Pairs() {
+			if pair.Replica != nil {
+				partners[pair.Master] = pair.Replica
+				partners[pair.Replica] = pair.Master
+			}
+		}
+	}
+
+	plan.Before = copyLoads(order)
+	balance := newBalance(order)
+
+	for step := 1; step <= maxSteps; step++ {
+		var best *Shard
+		var target *NodeLoad
+		improvement := -rebalanceThreshold
+
+		for _, shard := range c.Shards {
+			from, ok := loads[location[shard]]
+			if !ok {
+				continue
+			}
+			var partner NodeID
+			if p := partners[shard]; p != nil {
+				partner = location[p]
+			}
+
+			for _, to := range order {
+				if to == from || to.Shards >= to.MaxShards || to.FreeRAM < shard.UsedMemory {
+					continue
+				}
+				if partner != 0 && (to.Id == partner || (racks[partner] != "" && racks[partner] == to.RackId)) {
+					continue
+				}
+				if delta := balance.delta(shard, from, to); delta < improvement {
+					improvement = delta
+					best = shard
+					target = to
+				}
+			}
+		}
+
+		if best == nil {
+			break
+		}
+
+		from := loads[location[best]]
+		from.add(best, -1)
+		target.add(best, 1)
+		location[best] = target.Id
+
+		// masters are migrated with preserve_roles so they remain masters
+		plan.Steps = append(plan.Steps, &MigrationStep{
+			Step:    step,
+			Shard:   best.Id,
+			DBId:    best.DBId,
+			Role:    best.Role,
+			From:    from.Id,
+			To:      target.Id,
+			Size:    best.UsedMemory,
+			Command: MigrateShardCommand(best.Id, target.Id, best.Role == ShardRoleMaster),
+			Nodes:   copyLoads(order),
+		})
+	}
+
+	plan.After = copyLoads(order)
+	return plan
+}
+
+// Commands returns the rladmin commands for the plan in order
+func (p *RebalancePlan) Commands() []string {
+	commands := make([]string, len(p.Steps))
+	for n, step := range p.Steps {
+		commands[n] = step.Command
+	}
+	return commands
+}
+
+func (p *RebalancePlan) JSON() (string, error) {
+	if out, err := json.Marshal(p); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+// add adds (count 1) or removes (count -1) a shard from the node
+func (l *NodeLoad) add(shard *Shard, count int) {
+	size := shard.UsedMemory * RAMFloat(count)
+	l.UsedMemory += size
+	l.FreeRAM -= size
+	l.Shards = uint16(int(l.Shards) + count)
+	switch shard.Role {
+	case ShardRoleMaster:
+		l.Masters = uint16(int(l.Masters) + count)
+	case ShardRoleReplica:
+		l.Replicas = uint16(int(l.Replicas) + count)
+	}
+}
+
+func copyLoads(loads []*NodeLoad) []*NodeLoad {
+	copied := make([]*NodeLoad, len(loads))
+	for n, load := range loads {
+		c := *load
+		copied[n] = &c
+	}
+	return copied
+}
+
+// balance measures how uneven the nodes are as the sum of the squared deviations of memory
+// utilisation from the utilisation of the whole cluster and of master and replica counts from
+// their means, each relative to the target value. The targets don't change as shards are
+// migrated so only the nodes involved in a migration need to be considered.
+type balance struct {
+	utilisation float64
+	masters     float64
+	replicas    float64
+}
+
+func newBalance(loads []*NodeLoad) *balance {
+	b := &balance{}
+	var used, total float64
+	for _, load := range loads {
+		used += float64(load.UsedMemory)
+		total += float64(load.MaxRAM)
+		b.masters += float64(load.Masters)
+		b.replicas += float64(load.Replicas)
+	}
+	if total > 0 {
+		b.utilisation = used / total
+	}
+	if n := float64(len(loads)); n > 0 {
+		b.masters /= n
+		b.replicas /= n
+	}
+	return b
+}
+
+// delta returns the change in balance if the shard is migrated between the nodes
+func (b *balance) delta(shard *Shard, from, to *NodeLoad) float64 {
+	size := float64(shard.UsedMemory)
+	delta := 0.0
+	if from.MaxRAM > 0 && to.MaxRAM > 0 {
+		fromUsed := float64(from.UsedMemory / from.MaxRAM)
+		toUsed := float64(to.UsedMemory / to.MaxRAM)
+		delta += deviationDelta(fromUsed, fromUsed-size/float64(from.MaxRAM), toUsed, toUsed+size/float64(to.MaxRAM), b.utilisation)
+	}
+	switch shard.Role {
+	case ShardRoleMaster:
+		delta += deviationDelta(float64(from.Masters), float64(from.Masters)-1, float64(to.Masters), float64(to.Masters)+1, b.masters)
+	case ShardRoleReplica:
+		delta += deviationDelta(float64(from.Replicas), float64(from.Replicas)-1, float64(to.Replicas), float64(to.Replicas)+1, b.replicas)
+	}
+	return delta
+}
+
+// deviationDelta returns the change in squared deviation from target, relative to target,
+// when two values change.
+func deviationDelta(from, newFrom, to, newTo, target float64) float64 {
+	if target == 0 {
+		return 0
+	}
+	square := func(x float64) float64 { return (x - target) * (x - target) }
+	return (square(newFrom) + square(newTo) - square(from) - square(to)) / (target * target)
+}
+
+// MigrateShardCommand returns the rladmin command to migrate a shard to a node. It is
+// shared with commands.MigrateShard so the plan and the command builder agree.
+func MigrateShardCommand(shard ShardID, node NodeID, preserveRoles bool) string {
+	options := ""
+	if preserveRoles {
+		options = " preserve_roles"
+	}
+	return fmt.Sprintf("rladmin migrate shard %d%s target_node %d",
+		uint64(shard), options, uint64(node))
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanRebalance(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	plan := info.PlanRebalance(20)
+	assert.NotEmpty(t, plan.Steps)
+	assert.LessOrEqual(t, len(plan.Steps), 20)
+	assert.Len(t, plan.Commands(), len(plan.Steps))
+
+	spread := func(loads []*NodeLoad) RAMFloat {
+		low, high := loads[0].UsedMemory, loads[0].UsedMemory
+		for _, load := range loads {
+			low = min(low, load.UsedMemory)
+			high = max(high, load.UsedMemory)
+		}
+		return high - low
+	}
+	assert.Less(t, spread(plan.After), spread(plan.Before))
+
+	command := regexp.MustCompile(`^rladmin migrate shard \d+( preserve_roles)? target_node \d+$`)
+	for _, step := range plan.Steps {
+		assert.Regexp(t, command, step.Command)
+		assert.NotEqual(t, step.From, step.To)
+		for _, load := range step.Nodes {
+			assert.LessOrEqual(t, load.Shards, load.MaxShards)
+		}
+	}
+
+	// apply the plan and check it hasn't put a master and replica together
+	violations := func() int {
+		count := 0
+		for _, db := range info.RackAffinity() {
+			count += len(db.Violations)
+		}
+		return count
+	}
+	before := violations()
+	for _, step := range plan.Steps {
+		info.Shard(step.Shard).NodeId = step.To
+	}
+	info.Reindex()
+	assert.LessOrEqual(t, violations(), before)
+	assert.Equal(t, plan.After[0].Shards, uint16(len(info.Node(plan.After[0].Id).Shards())))
+
+	_, err = plan.JSON()
+	assert.Nil(t, err)
+}
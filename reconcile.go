@@ -0,0 +1,267 @@
+/*
+reconcile.go compares cluster information captured on different nodes of the same cluster
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReconcileSource describes one set of cluster information passed to Reconcile
+type ReconcileSource struct {
+	Key        string    `json:"key"`
+	SourceNode NodeID    `json:"sourceNode"`
+	TimeStamp  time.Time `json:"timeStamp"`
+}
+
+// Observation is a value and the sources which saw it. Sources are indexes into the Sources
+// of the report, so sources with the same key can be told apart.
+type Observation struct {
+	Value   string `json:"value"`
+	Sources []int  `json:"sources"`
+}
+
+// Disagreement records an attribute of an entity which the sources don't agree on. An entity
+// which some sources don't have is reported with the attribute "present" and the values
+// "present" and "missing". Other attributes are only compared between the sources which
+// have the entity.
+type Disagreement struct {
+	Entity    string        `json:"entity"`
+	Attribute string        `json:"attribute"`
+	Seen      []Observation `json:"seen"`
+}
+
+// ConsistencyReport is the result of reconciling cluster information from several nodes
+type ConsistencyReport struct {
+	Sources       []ReconcileSource `json:"sources"`
+	Disagreements []*Disagreement   `json:"disagreements"`
+}
+
+// attribute is a named value compared between sources
+type attribute[T any] struct {
+	name  string
+	value func(T) string
+}
+
+// Reconcile checks that every set of cluster information describes the same cluster. Nodes,
+// databases, shards and endpoints are compared along with the values which should be the
+// same whichever node rladmin was run on - placement, roles and status. Usage figures
+// change from moment to moment and aren't compared.
+func Reconcile(infos []*ClusterInfo) *ConsistencyReport {
+	r := &ConsistencyReport{Sources: []ReconcileSource{}, Disagreements: []*Disagreement{}}
+	for _, info := range infos {
+		r.Sources = append(r.Sources, ReconcileSource{Key: info.Key, SourceNode: info.SourceNode, TimeStamp: info.TimeStamp})
+	}
+	if len(infos) < 2 {
+		return r
+	}
+
+	reconcileEntities(r, infos, func(c *ClusterInfo) map[string]*ClusterStatus {
+		if c.Cluster == nil {
+			return map[string]*ClusterStatus{}
+		}
+		return map[string]*ClusterStatus{"cluster": c.Cluster}
+	}, func(id string) string { return id }, strings.Compare, []attribute[*ClusterStatus]{
+		{"master", func(s *ClusterStatus) string { return s.MasterNode.String() }},
+		{"masterAddress", func(s *ClusterStatus) string { return s.MasterAddress.String() }},
+	})
+
+	reconcileEntities(r, infos, func(c *ClusterInfo) map[NodeID]*Node {
+		nodes := map[NodeID]*Node{}
+		for _, node := range c.Nodes {
+			nodes[node.Id] = node
+		}
+		return nodes
+	}, NodeID.String, cmp.Compare[NodeID], []attribute[*Node]{
+		{"role", func(n *Node) string { return string(n.Role) }},
+		{"address", func(n *Node) string { return n.Address.String() }},
+		{"status", func(n *Node) string { return string(n.Status) }},
+		{"version", func(n *Node) string { return n.Version }},
+	})
+
+	reconcileEntities(r, infos, func(c *ClusterInfo) map[DBID]*Database {
+		dbs := map[DBID]*Database{}
+		for _, db := range c.Databases {
+			dbs[db.Id] = db
+		}
+		return dbs
+	}, DBID.String, cmp.Compare[DBID], []attribute[*Database]{
+		{"name", func(d *Database) string { return d.Name }},
+		{"shards", func(d *Database) string { return strconv.Itoa(int(d.MasterShards)) }},
+		{"status", func(d *Database) string { return string(d.Status) }},
+	})
+
+	reconcileEntities(r, infos, func(c *ClusterInfo) map[ShardID]*Shard {
+		shards := map[ShardID]*Shard{}
+		for _, shard := range c.Shards {
+			shards[shard.Id] = shard
+		}
+		return shards
+	}, ShardID.String, cmp.Compare[ShardID], []attribute[*Shard]{
+		{"node", func(s *Shard) string { return s.NodeId.String() }},
+		{"role", func(s *Shard) string { return string(s.Role) }},
+		{"slots", func(s *Shard) string { return s.Slots.String() }},
+		{"status", func(s *Shard) string { return string(s.Status) }},
+	})
+
+	reconcileEntities(r, infos, func(c *ClusterInfo) map[EndpointID]string {
+		return endpointNodes(c.Endpoints)
+	}, EndpointID.String, EndpointID.Compare, []attribute[string]{
+		{"nodes", func(nodes string) string { return nodes }},
+	})
+
+	return r
+}
+
+// reconcileEntities compares one kind of entity across the sources. Entities are reported in
+// id order.
+func reconcileEntities[K comparable, T any](r *ConsistencyReport, infos []*ClusterInfo, entities func(*ClusterInfo) map[K]T,
+	name func(K) string, compare func(K, K) int, attributes []attribute[T]) {
+
+	found := make([]map[K]T, len(infos))
+	ids := []K{}
+	seen := map[K]bool{}
+	for n, info := range infos {
+		found[n] = entities(info)
+		for id := range found[n] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.SortFunc(ids, compare)
+
+	for _, id := range ids {
+		present := make([]bool, len(infos))
+		presence := make([]string, len(infos))
+		for n := range infos {
+			_, present[n] = found[n][id]
+			presence[n] = "missing"
+			if present[n] {
+				presence[n] = "present"
+			}
+		}
+		r.compare(name(id), "present", presence, nil)
+
+		for _, attr := range attributes {
+			values := make([]string, len(infos))
+			for n := range infos {
+				if present[n] {
+					values[n] = attr.value(found[n][id])
+				}
+			}
+			r.compare(name(id), attr.name, values, present)
+		}
+	}
+}
+
+// compare adds a disagreement if the values seen by the sources differ. If present is
+// given, sources which don't have the entity are left out.
+func (r *ConsistencyReport) compare(entity, attribute string, values []string, present []bool) {
+	seen := []Observation{}
+	for n, value := range values {
+		if present != nil && !present[n] {
+			continue
+		}
+		index := slices.IndexFunc(seen, func(o Observation) bool { return o.Value == value })
+		if index < 0 {
+			seen = append(seen, Observation{Value: value})
+			index = len(seen) - 1
+		}
+		seen[index].Sources = append(seen[index].Sources, n)
+	}
+
+	if len(seen) > 1 {
+		r.Disagreements = append(r.Disagreements, &Disagreement{Entity: entity, Attribute: attribute, Seen: seen})
+	}
+}
+
+// Reconcile compares the cluster information from every node in the package, in node name order
+func (p SupportPackage) Reconcile() *ConsistencyReport {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]*ClusterInfo, len(names))
+	for n, name := range names {
+		infos[n] = p[name]
+	}
+	return Reconcile(infos)
+}
+
+// Consistent returns true if the sources agree on everything compared
+func (r *ConsistencyReport) Consistent() bool {
+	return len(r.Disagreements) == 0
+}
+
+// ForEntity returns the disagreements about a single entity
+func (r *ConsistencyReport) ForEntity(entity string) []*Disagreement {
+	found := []*Disagreement{}
+	for _, d := range r.Disagreements {
+		if d.Entity == entity {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+func (r *ConsistencyReport) JSON() (string, error) {
+	if out, err := json.Marshal(r); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+// sourceName returns the key of a source, followed by its position if another source has
+// the same key
+func (r *ConsistencyReport) sourceName(source int) string {
+	key := r.Sources[source].Key
+	for n, other := range r.Sources {
+		if n != source && other.Key == key {
+			return fmt.Sprintf("%s #%d", key, source+1)
+		}
+	}
+	return key
+}
+
+// Text renders the disagreements in a human readable form, one per line.
+func (r *ConsistencyReport) Text() string {
+	out := &strings.Builder{}
+
+	for _, source := range r.Sources {
+		node := source.SourceNode.String()
+		if node == "" {
+			node = "an unknown node"
+		}
+		fmt.Fprintf(out, "%s captured on %s at %s\n", source.Key, node, source.TimeStamp.Format(time.RFC3339))
+	}
+	if r.Consistent() {
+		fmt.Fprintln(out, "no disagreements")
+		return out.String()
+	}
+
+	for _, d := range r.Disagreements {
+		seen := []string{}
+		for _, o := range d.Seen {
+			sources := make([]string, len(o.Sources))
+			for n, source := range o.Sources {
+				sources[n] = r.sourceName(source)
+			}
+			seen = append(seen, fmt.Sprintf("%s (%s)", o.Value, strings.Join(sources, ", ")))
+		}
+		fmt.Fprintf(out, "%s %s: %s\n", d.Entity, d.Attribute, strings.Join(seen, " vs "))
+	}
+	return out.String()
+}
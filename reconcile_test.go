@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	node1, err := NewClusterInfo("node_1", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	// the same cluster seen from node:3 which hasn't caught up with a failover of redis:5
+	view := bytes.Replace(rladmin, []byte("*node:1 slave"), []byte("node:1  slave"), 1)
+	view = bytes.Replace(view, []byte("node:3  master"), []byte("*node:3 master"), 1)
+	view = bytes.Replace(view, []byte("redis:5   node:7  master"), []byte("redis:5   node:8  master"), 1)
+	node3, err := NewClusterInfo("node_3", bytes.NewReader(view))
+	assert.Nil(t, err)
+
+	report := Reconcile([]*ClusterInfo{node1, node1})
+	assert.True(t, report.Consistent())
+
+	report = Reconcile([]*ClusterInfo{node1, node3})
+	assert.Equal(t, NodeID(3), report.Sources[1].SourceNode)
+	if assert.Len(t, report.Disagreements, 1) {
+		assert.Equal(t, &Disagreement{
+			Entity:    "redis:5",
+			Attribute: "node",
+			Seen:      []Observation{{Value: "node:7", Sources: []int{0}}, {Value: "node:8", Sources: []int{1}}},
+		}, report.Disagreements[0])
+	}
+	assert.Len(t, report.ForEntity("redis:5"), 1)
+	assert.Contains(t, report.Text(), "redis:5 node: node:7 (node_1) vs node:8 (node_3)")
+
+	// sources with the same key are told apart by position
+	node3.Key = node1.Key
+	report = Reconcile([]*ClusterInfo{node1, node3})
+	assert.Contains(t, report.Text(), "redis:5 node: node:7 (node_1 #1) vs node:8 (node_1 #2)")
+}
+
+func TestReconcileDifferentClusters(t *testing.T) {
+	pkg := SupportPackage{}
+	for key, data := range map[string][]byte{"node_1": rladmin, "node_2": rsOutput} {
+		info, err := NewClusterInfo(key, bytes.NewReader(data))
+		assert.Nil(t, err)
+		pkg[key] = info
+	}
+
+	report := pkg.Reconcile()
+	assert.False(t, report.Consistent())
+	assert.Equal(t, "node_1", report.Sources[0].Key)
+	if found := report.ForEntity("db:10"); assert.NotEmpty(t, found) {
+		assert.Equal(t, "present", found[0].Attribute)
+		assert.Equal(t, []Observation{{Value: "missing", Sources: []int{0}}, {Value: "present", Sources: []int{1}}}, found[0].Seen)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+redact.go replaces identifying information in cluster information with stable pseudonyms
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	redactIP       = "ip"
+	redactHost     = "host"
+	redactDatabase = "database"
+	redactEndpoint = "endpoint"
+	redactSHA      = "sha"
+)
+
+// Redactor replaces IP addresses, host names, database names, endpoint hosts and SHA values
+// with pseudonyms. The same value is always given the same pseudonym by a Redactor. Without a
+// key, pseudonyms are numbered in the order values are seen. With a key, pseudonyms are derived
+// from an HMAC of the value so they are also stable between runs using the same key.
+type Redactor struct {
+	key        []byte
+	pseudonyms map[string]map[string]string
+	used       map[string]map[string]bool
+}
+
+// NewRedactor returns a Redactor. key may be nil.
+func NewRedactor(key []byte) *Redactor {
+	return &Redactor{
+		key:        key,
+		pseudonyms: map[string]map[string]string{},
+		used:       map[string]map[string]bool{},
+	}
+}
+
+// Redact returns a copy of the cluster information with identifying values replaced. If the
+// information was parsed, the unparsed chunks are replaced by redacted chunks rendered from
+// the copy.
+func (r *Redactor) Redact(c *ClusterInfo) (*ClusterInfo, error) {
+	redacted := &ClusterInfo{
+		Key:        c.Key,
+		Unparsed:   c.Unparsed,
+		SourceNode: c.SourceNode,
+		TimeStamp:  c.TimeStamp,
+		Databases:  make(Databases, len(c.Databases)),
+		Endpoints:  make(Endpoints, len(c.Endpoints)),
+		Shards:     make(Shards, len(c.Shards)),
+		Nodes:      make(Nodes, len(c.Nodes)),
+	}
+
+	if c.Cluster != nil {
+		cluster := *c.Cluster
+		cluster.parent = redacted
+		cluster.MasterAddress = r.IP(cluster.MasterAddress)
+		redacted.Cluster = &cluster
+	}
+
+	for n, node := range c.Nodes {
+		copied := *node
+		copied.parent = redacted
+		copied.Address = r.IP(node.Address)
+		copied.ExternalAddress = r.IP(node.ExternalAddress)
+		copied.HostName = r.HostName(node.HostName)
+		copied.SHA = r.SHA(node.SHA)
+		redacted.Nodes[n] = &copied
+	}
+
+	for n, db := range c.Databases {
+		copied := *db
+		copied.parent = redacted
+		copied.Name = r.DatabaseName(db.Name)
+		copied.Endpoint = make(DBEndPoints, len(db.Endpoint))
+		for i, endpoint := range db.Endpoint {
+			copied.Endpoint[i] = r.Endpoint(endpoint)
+		}
+		redacted.Databases[n] = &copied
+	}
+
+	for n, endpoint := range c.Endpoints {
+		copied := *endpoint
+		copied.parent = redacted
+		copied.Name = r.DatabaseName(endpoint.Name)
+		redacted.Endpoints[n] = &copied
+	}
+
+	for n, shard := range c.Shards {
+		copied := *shard
+		copied.parent = redacted
+		copied.Name = r.DatabaseName(shard.Name)
+		redacted.Shards[n] = &copied
+	}
+
+	redacted.Reindex()
+
+	if c.Unparsed != nil {
+		text, err := redacted.Render()
+		if err != nil {
+			return nil, err
+		}
+		chunks := &Chunks{}
+		if err := chunks.Parse(strings.NewReader(text)); err != nil {
+			return nil, err
+		}
+		redacted.Unparsed = chunks
+	}
+
+	return redacted, nil
+}
+
+// RedactChunks parses the chunks and returns redacted chunks rendered from the result.
+func (r *Redactor) RedactChunks(c *Chunks) (*Chunks, error) {
+	info, _, err := c.clusterInfo("", ParseOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	redacted, err := r.Redact(info)
+	if err != nil {
+		return nil, err
+	}
+	return redacted.Unparsed, nil
+}
+
+// IP returns the pseudonym for an IP address. IPv4 addresses are replaced by addresses
+// in 10.0.0.0/8 and IPv6 addresses by addresses in fd00::/8.
+func (r *Redactor) IP(ip IP) IP {
+	if ip.IP == nil {
+		return ip
+	}
+
+	v4 := ip.To4() != nil
+	pseudonym := r.pseudonym(redactIP, ip.String(), func(n uint64) string {
+		if v4 {
+			return fmt.Sprintf("10.%d.%d.%d", byte(n>>16), byte(n>>8), byte(n))
+		}
+		address := make(net.IP, net.IPv6len)
+		address[0] = 0xfd
+		binary.BigEndian.PutUint64(address[8:], n)
+		return address.String()
+	})
+
+	return IP{net.ParseIP(pseudonym)}
+}
+
+// HostName returns the pseudonym for a host name
+func (r *Redactor) HostName(name string) string {
+	return r.pseudonym(redactHost, name, func(n uint64) string {
+		return "host-" + r.suffix(n)
+	})
+}
+
+// DatabaseName returns the pseudonym for a database name
+func (r *Redactor) DatabaseName(name string) string {
+	return r.pseudonym(redactDatabase, name, func(n uint64) string {
+		return "db-" + r.suffix(n)
+	})
+}
+
+// Endpoint returns the pseudonym for a database endpoint. Only the host is replaced;
+// the port is kept.
+func (r *Redactor) Endpoint(endpoint string) string {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host, port = endpoint, ""
+	}
+
+	host = r.pseudonym(redactEndpoint, host, func(n uint64) string {
+		return "endpoint-" + r.suffix(n) + ".example.com"
+	})
+
+	if port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// SHA returns a pseudonym for a SHA value of the same length
+func (r *Redactor) SHA(sha string) string {
+	return r.pseudonym(redactSHA, sha, func(n uint64) string {
+		digits := fmt.Sprintf("%016x", n)
+		if len(sha) <= len(digits) {
+			return digits[len(digits)-len(sha):]
+		}
+		return strings.Repeat("0", len(sha)-len(digits)) + digits
+	})
+}
+
+// suffix formats n for use in a name pseudonym. Sequential pseudonyms are
+// numbered; keyed pseudonyms use the hex value.
+func (r *Redactor) suffix(n uint64) string {
+	if r.key == nil {
+		return strconv.FormatUint(n, 10)
+	}
+	return fmt.Sprintf("%08x", uint32(n))
+}
+
+// pseudonym returns the pseudonym for a value of the given kind, creating one with format
+// if the value hasn't been seen. Empty values are not replaced. If a keyed pseudonym collides
+// with one already in use, the HMAC is recalculated with a counter until it doesn't.
+func (r *Redactor) pseudonym(kind, value string, format func(uint64) string) string {
+	if value == "" {
+		return value
+	}
+
+	if r.pseudonyms[kind] == nil {
+		r.pseudonyms[kind] = map[string]string{}
+		r.used[kind] = map[string]bool{}
+	}
+	if pseudonym, ok := r.pseudonyms[kind][value]; ok {
+		return pseudonym
+	}
+
+	var pseudonym string
+	for attempt := 0; pseudonym == "" || r.used[kind][pseudonym]; attempt++ {
+		if r.key == nil {
+			pseudonym = format(uint64(len(r.pseudonyms[kind]) + 1 + attempt))
+		} else {
+			pseudonym = format(r.digest(kind, value, attempt))
+		}
+	}
+
+	r.pseudonyms[kind][value] = pseudonym
+	r.used[kind][pseudonym] = true
+	return pseudonym
+}
+
+func (r *Redactor) digest(kind, value string, attempt int) uint64 {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(kind + "\x00" + value))
+	if attempt > 0 {
+		mac.Write([]byte("\x00" + strconv.Itoa(attempt)))
+	}
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
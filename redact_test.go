@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	redacted, err := NewRedactor(nil).Redact(info)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "10.10.21.4", info.Nodes[0].Address.String())
+	assert.Equal(t, "sudan-02", info.Database(10567021).Name)
+
+	assert.Len(t, redacted.Nodes, len(info.Nodes))
+	assert.Len(t, redacted.Shards, len(info.Shards))
+	assert.Equal(t, "10.0.0.2", redacted.Nodes[0].Address.String())
+	assert.Equal(t, "host-1", redacted.Nodes[0].HostName)
+	assert.Equal(t, "000001", redacted.Nodes[0].SHA)
+	assert.Equal(t, redacted.Nodes[0].SHA, redacted.Nodes[1].SHA)
+
+	// the cluster master address and the master node's address are the same
+	master := redacted.Node(redacted.Cluster.MasterNode)
+	assert.Equal(t, master.Address.String(), redacted.Cluster.MasterAddress.String())
+
+	// database names are consistent across sections
+	db := redacted.Database(10567021)
+	assert.Equal(t, "db-1", db.Name)
+	for _, shard := range db.Shards() {
+		assert.Equal(t, db.Name, shard.Name)
+	}
+	for _, endpoint := range db.Endpoints() {
+		assert.Equal(t, db.Name, endpoint.Name)
+	}
+	assert.Equal(t, "endpoint-1.example.com:17798", db.Endpoint[0])
+
+	text, err := redacted.Render()
+	assert.Nil(t, err)
+	for _, value := range []string{"10.10.21", "node7", "sudan-02", "b799d6", "rlrcp.com", "55.136.114.84"} {
+		assert.NotContains(t, text, value)
+	}
+	assert.NotContains(t, string(redacted.Unparsed.Databases), "sudan-02")
+
+	reparsed, err := NewClusterInfo("test", bytes.NewReader([]byte(text)))
+	assert.Nil(t, err)
+	assert.True(t, Diff(redacted, reparsed).Empty())
+}
+
+func TestRedactKeyed(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+
+	first, err := NewRedactor([]byte("secret")).Redact(info)
+	assert.Nil(t, err)
+	second, err := NewRedactor([]byte("secret")).Redact(info)
+	assert.Nil(t, err)
+	other, err := NewRedactor([]byte("other")).Redact(info)
+	assert.Nil(t, err)
+
+	assert.Equal(t, first.Nodes[3].Address.String(), second.Nodes[3].Address.String())
+	assert.Equal(t, first.Nodes[3].HostName, second.Nodes[3].HostName)
+	assert.Equal(t, first.Databases[2].Name, second.Databases[2].Name)
+	assert.NotEqual(t, first.Databases[2].Name, other.Databases[2].Name)
+	assert.NotEqual(t, info.Nodes[0].SHA, first.Nodes[0].SHA)
+	assert.Len(t, first.Nodes[0].SHA, len(info.Nodes[0].SHA))
+}
+
+func TestRedactChunks(t *testing.T) {
+	chunks := &Chunks{}
+	assert.Nil(t, chunks.Parse(bytes.NewReader(rsOutput)))
+
+	redactor := NewRedactor(nil)
+	redacted, err := redactor.RedactChunks(chunks)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(redacted.Databases), "REDISCACHE001")
+	assert.NotContains(t, string(redacted.Endpoints), "REDISCACHE001")
+	assert.Contains(t, string(redacted.Databases), "endpoint-1.example.com:7001")
+
+	// the same redactor gives the same pseudonyms
+	assert.Equal(t, "db-1", redactor.DatabaseName("REDISCACHE001"))
+
+	info, _, err := redacted.clusterInfo("test", ParseOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, info.Shards, 60)
+}
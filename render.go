@@ -0,0 +1,326 @@
+/*
+render.go writes cluster information back out in the format used by rladmin status
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nic-gibson/go-bytesize"
+)
+
+const (
+	introTitle  = "Redis Enterprise Node Information"
+	introRule   = "------------------------------------------------------------"
+	introStatus = "rladmin status extra all:"
+)
+
+// column describes how to render one column of a section
+type column[T any] struct {
+	header string
+	value  func(T) string
+}
+
+var nodeColumns = []column[*Node]{
+	{"NODE:ID", func(n *Node) string { return n.renderId() }},
+	{"ROLE", func(n *Node) string { return string(n.Role) }},
+	{"ADDRESS", func(n *Node) string { return n.Address.String() }},
+	{"EXTERNAL_ADDRESS", func(n *Node) string { return n.ExternalAddress.String() }},
+	{"HOSTNAME", func(n *Node) string { return n.HostName }},
+	{"MASTERS", func(n *Node) string { return strconv.Itoa(int(n.Masters)) }},
+	{"SLAVES", func(n *Node) string { return strconv.Itoa(int(n.Replicas)) }},
+	{"OVERBOOKING_DEPTH", func(n *Node) string { return n.OverbookingDepth.String() }},
+	{"SHARDS", func(n *Node) string { return n.ShardUsage.String() }},
+	{"CORES", func(n *Node) string { return strconv.Itoa(int(n.Cores)) }},
+	{"FREE_RAM", func(n *Node) string { return n.RedisRAM.String() }},
+	{"PROVISIONAL_RAM", func(n *Node) string { return n.ProvisionalRAM.String() }},
+	{"VERSION", func(n *Node) string { return n.Version }},
+	{"SHA", func(n *Node) string { return n.SHA }},
+	{"RACK-ID", func(n *Node) string { return n.RackId }},
+	{"STATUS", func(n *Node) string { return string(n.Status) }},
+}
+
+var databaseColumns = []column[*Database]{
+	{"DB:ID", func(d *Database) string { return d.Id.String() }},
+	{"NAME", func(d *Database) string { return d.Name }},
+	{"TYPE", func(d *Database) string { return d.Type }},
+	{"STATUS", func(d *Database) string { return string(d.Status) }},
+	{"SHARDS", func(d *Database) string { return strconv.Itoa(int(d.MasterShards)) }},
+	{"PLACEMENT", func(d *Database) string { return string(d.Placement) }},
+	{"REPLICATION", func(d *Database) string { return string(d.Replication) }},
+	{"PERSISTENCE", func(d *Database) string { return string(d.Persistence) }},
+	{"ENDPOINT", func(d *Database) string { return strings.Join(d.Endpoint, "/") }},
+	{"EXEC_STATE", func(d *Database) string { return string(d.ExecState) }},
+	{"EXEC_STATE_MACHINE", func(d *Database) string { return d.ExecStateMachine }},
+	{"BACKUP_PROGRESS", func(d *Database) string { return d.BackupProgress }},
+	{"MISSING_BACKUP_TIME", func(d *Database) string { return d.MissingBackupTime }},
+	{"REDIS_VERSION", func(d *Database) string { return d.RedisVersion }},
+}
+
+var endpointColumns = []column[*Endpoint]{
+	{"DB:ID", func(e *Endpoint) string { return e.DBId.String() }},
+	{"NAME", func(e *Endpoint) string { return e.Name }},
+	{"ID", func(e *Endpoint) string { return e.Id.String() }},
+	{"NODE", func(e *Endpoint) string { return e.NodeId.String() }},
+	{"ROLE", func(e *Endpoint) string { return string(e.Role) }},
+	{"SSL", func(e *Endpoint) string { return renderBool(e.SSL) }},
+	{"WATCHDOG_STATUS", func(e *Endpoint) string { return string(e.WatchdogStatus) }},
+}
+
+var shardColumns = []column[*Shard]{
+	{"DB:ID", func(s *Shard) string { return s.DBId.String() }},
+	{"NAME", func(s *Shard) string { return s.Name }},
+	{"ID", func(s *Shard) string { return s.Id.String() }},
+	{"NODE", func(s *Shard) string { return s.NodeId.String() }},
+	{"ROLE", func(s *Shard) string { return string(s.Role) }},
+	{"SLOTS", func(s *Shard) string { return s.Slots.String() }},
+	{"USED_MEMORY", func(s *Shard) string { return s.UsedMemory.String() }},
+	{"BACKUP_PROGRESS", func(s *Shard) string { return s.BackupProgress }},
+	{"RAM_FRAG", func(s *Shard) string { return s.RAMFrag.String() }},
+	{"WATCHDOG_STATUS", func(s *Shard) string { return string(s.WatchdogStatus) }},
+	{"STATUS", func(s *Shard) string { return string(s.Status) }},
+}
+
+// Render returns the cluster information formatted as rladmin status extra all output.
+func (c *ClusterInfo) Render() (string, error) {
+	out := &strings.Builder{}
+	_, err := c.WriteTo(out)
+	return out.String(), err
+}
+
+// WriteTo writes the cluster information to w formatted as rladmin status extra all output.
+// If the information was parsed from rladmin output, the original column widths are kept
+// where the values still fit so unmodified input is reproduced exactly.
+func (c *ClusterInfo) WriteTo(w io.Writer) (int64, error) {
+	out := &bytes.Buffer{}
+
+	fmt.Fprintf(out, "%s\n%s\n\n%s\n%s\n", introTitle, c.TimeStamp.Format(timeStampFormat), introRule, introStatus)
+
+	if c.Cluster != nil {
+		fmt.Fprintf(out, "%s:\n", chunkName(ChunkCluster))
+		c.Cluster.render(out)
+		out.WriteString("\n")
+	}
+
+	renderSection(out, ChunkNodes, c.originalHeader(ChunkNodes), nodeColumns, c.Nodes)
+	renderSection(out, ChunkDatabases, c.originalHeader(ChunkDatabases), databaseColumns, c.Databases)
+	renderSection(out, ChunkEndpoints, c.originalHeader(ChunkEndpoints), endpointColumns, c.Endpoints)
+	renderSection(out, ChunkShards, c.originalHeader(ChunkShards), shardColumns, c.Shards)
+
+	return out.WriteTo(w)
+}
+
+// renderId returns the node id with the "*" prefix rladmin uses to mark
+// the node it was run on.
+func (n *Node) renderId() string {
+	if n.IsLocal {
+		return "*" + n.Id.String()
+	}
+	return n.Id.String()
+}
+
+// originalHeader returns the header line of a section as it was parsed
+func (c *ClusterInfo) originalHeader(chunk int) string {
+	if c.Unparsed == nil {
+		return ""
+	}
+
+	var data []byte
+	switch chunk {
+	case ChunkNodes:
+		data = c.Unparsed.Nodes
+	case ChunkDatabases:
+		data = c.Unparsed.Databases
+	case ChunkEndpoints:
+		data = c.Unparsed.Endpoints
+	case ChunkShards:
+		data = c.Unparsed.Shards
+	}
+
+	header, _, _ := strings.Cut(string(data), "\n")
+	return header
+}
+
+func (s *ClusterStatus) render(out io.Writer) {
+	if s.MasterNode != 0 {
+		fmt.Fprintf(out, "%s. Cluster master: %d (%s)\n", s.Status, uint64(s.MasterNode), s.MasterAddress.String())
+	}
+	fmt.Fprintf(out, "Cluster health: %s, [%s, %s, %s]\n", s.Health,
+		pythonNumber(s.Failures.Avg1, true), pythonNumber(s.Failures.Avg15, false), pythonNumber(s.Failures.Avg60, false))
+	fmt.Fprintf(out, "failures/minute - avg1 %0.2f, avg15 %0.2f, avg60 %0.2f.\n", s.Failures.Avg1, s.Failures.Avg15, s.Failures.Avg60)
+}
+
+// renderSection writes a section. Each column starts where its header starts, or one
+// space after the end of the previous value if that overruns (as rladmin does), and
+// every line is padded to the length of the header. The layout of the original header
+// is reused if the columns match and every value still fits within its column. Otherwise,
+// each column is made wide enough for its widest value.
+func renderSection[T any](out *bytes.Buffer, chunk int, original string, columns []column[T], items []T) {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.header
+	}
+
+	rows := make([][]string, len(items))
+	for n, item := range items {
+		rows[n] = make([]string, len(columns))
+		for i, col := range columns {
+			rows[n][i] = col.value(item)
+		}
+	}
+
+	starts, ends, ok := originalLayout(original, header)
+	if ok {
+		for _, row := range rows {
+			if !fitsLayout(row, starts, ends) {
+				ok = false
+				break
+			}
+		}
+	}
+	if !ok {
+		starts, ends = computeLayout(header, rows)
+	}
+
+	fmt.Fprintf(out, "%s:\n", chunkName(chunk))
+	out.WriteString(layoutLine(header, starts, ends[len(ends)-1]))
+	out.WriteString("\n")
+	for _, row := range rows {
+		out.WriteString(layoutLine(row, starts, ends[len(ends)-1]))
+		out.WriteString("\n")
+	}
+	out.WriteString("\n")
+}
+
+// originalLayout returns the start and end of each column in the original header
+// if the columns match the headers expected. Positions are counted in runes as
+// the parser slices rows by rune.
+func originalLayout(original string, header []string) ([]int, []int, bool) {
+	spans := headerColumn.FindAllStringIndex(original, -1)
+	if len(spans) != len(header) {
+		return nil, nil, false
+	}
+
+	starts := make([]int, len(spans))
+	ends := make([]int, len(spans))
+	for i, span := range spans {
+		if strings.TrimSpace(original[span[0]:span[1]]) != header[i] {
+			return nil, nil, false
+		}
+		starts[i] = utf8.RuneCountInString(original[:span[0]])
+		ends[i] = starts[i] + utf8.RuneCountInString(original[span[0]:span[1]])
+	}
+	return starts, ends, true
+}
+
+// computeLayout makes every column one space wider than its widest value
+func computeLayout(header []string, rows [][]string) ([]int, []int) {
+	starts := make([]int, len(header))
+	ends := make([]int, len(header))
+	pos := 0
+	for i := range header {
+		width := utf8.RuneCountInString(header[i])
+		for _, row := range rows {
+			width = max(width, utf8.RuneCountInString(row[i]))
+		}
+		if i < len(header)-1 {
+			width++
+		}
+		starts[i] = pos
+		pos += width
+		ends[i] = pos
+	}
+	return starts, ends
+}
+
+// fitsLayout returns true if every value in the row ends within its column
+func fitsLayout(row []string, starts, ends []int) bool {
+	pos := 0
+	for i, cell := range row {
+		pos = cellStart(i, pos, starts) + utf8.RuneCountInString(cell)
+		if pos > ends[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func layoutLine(cells []string, starts []int, length int) string {
+	line := &strings.Builder{}
+	pos := 0
+	for i, cell := range cells {
+		start := cellStart(i, pos, starts)
+		line.WriteString(strings.Repeat(" ", start-pos))
+		line.WriteString(cell)
+		pos = start + utf8.RuneCountInString(cell)
+	}
+	line.WriteString(strings.Repeat(" ", max(0, length-pos)))
+	return line.String()
+}
+
+// cellStart returns the position of a value given the end of the previous value
+func cellStart(i, pos int, starts []int) int {
+	if i == 0 {
+		return starts[0]
+	}
+	return max(starts[i], pos+1)
+}
+
+// String formats the value the way rladmin does, using the largest unit
+// which keeps the value at or above one with up to two decimal places.
+func (g RAMFloat) String() string {
+	value := math.Abs(float64(g) * float64(bytesize.GB))
+	sign := ""
+	if g < 0 {
+		sign = "-"
+	}
+
+	if value == 0 {
+		return "0KB"
+	}
+
+	units := []struct {
+		size bytesize.ByteSize
+		name string
+	}{{bytesize.TB, "TB"}, {bytesize.GB, "GB"}, {bytesize.MB, "MB"}, {bytesize.KB, "KB"}}
+
+	for _, unit := range units {
+		if value >= float64(unit.size) {
+			return sign + strconv.FormatFloat(math.Round(value/float64(unit.size)*100)/100, 'f', -1, 64) + unit.name
+		}
+	}
+
+	return sign + strconv.FormatFloat(math.Round(value), 'f', -1, 64) + "B"
+}
+
+func (m MemoryInfo) String() string {
+	return m.Free.String() + "/" + m.Max.String()
+}
+
+func (s ShardInfo) String() string {
+	return fmt.Sprintf("%d/%d", s.InUse, s.Max)
+}
+
+func renderBool(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+// pythonNumber formats a value as python's repr would. The first health
+// value is an integer if it has no fractional part.
+func pythonNumber(f float64, integer bool) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !integer && !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
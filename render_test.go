@@ -0,0 +1,67 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderRoundTrip(t *testing.T) {
+	for name, input := range map[string][]byte{"node_1": rladmin, "node_2": rsOutput} {
+		info, err := NewClusterInfo(name, bytes.NewReader(input))
+		if assert.Nil(t, err) {
+			out, err := info.Render()
+			assert.Nil(t, err)
+			assert.Equal(t, string(input), out, name)
+		}
+	}
+}
+
+func TestRenderModified(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+
+	info.Unparsed = nil
+	info.Databases[0].Name = "A_MUCH_LONGER_DATABASE_NAME"
+	info.Shards[0].UsedMemory = 10.5
+
+	buffer := &bytes.Buffer{}
+	_, err = info.WriteTo(buffer)
+	assert.Nil(t, err)
+
+	reparsed, err := NewClusterInfo("test", buffer)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "A_MUCH_LONGER_DATABASE_NAME", reparsed.Databases[0].Name)
+		assert.Equal(t, RAMFloat(10.5), reparsed.Shards[0].UsedMemory)
+		assert.Equal(t, info.Cluster.Failures, reparsed.Cluster.Failures)
+		assert.Len(t, reparsed.Nodes, len(info.Nodes))
+	}
+}
+
+func TestRenderNonASCII(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+
+	// widths are counted in runes so columns after the name stay aligned
+	info.Databases[0].Name = "données-café-ünïcödé"
+	for _, unparsed := range []*Chunks{info.Unparsed, nil} {
+		info.Unparsed = unparsed
+		out, err := info.Render()
+		assert.Nil(t, err)
+
+		reparsed, err := NewClusterInfo("test", bytes.NewReader([]byte(out)))
+		if assert.Nil(t, err) {
+			assert.Equal(t, "données-café-ünïcödé", reparsed.Databases[0].Name)
+			assert.Equal(t, info.Databases[0].Status, reparsed.Databases[0].Status)
+			assert.Equal(t, info.Databases[0].Endpoint, reparsed.Databases[0].Endpoint)
+
+			again, err := reparsed.Render()
+			assert.Nil(t, err)
+			assert.Equal(t, out, again)
+		}
+	}
+}
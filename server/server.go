@@ -0,0 +1,429 @@
+/*
+server.go provides an http.Handler which stores uploaded rladmin output and serves the parsed data
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package server
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goslogan/clusterinfo"
+)
+
+// DefaultMaxUploadSize is the largest upload accepted unless MaxUploadSize is changed
+const DefaultMaxUploadSize = 32 << 20
+
+const (
+	contentJSON = "application/json"
+	contentCSV  = "text/csv"
+)
+
+// Server stores cluster information in memory by key and serves it over HTTP. The routes are
+//
+//	GET    /clusters                    keys of the stored clusters
+//	POST   /clusters/{key}              upload rladmin status output as a multipart file or the body
+//	GET    /clusters/{key}              the cluster information
+//	DELETE /clusters/{key}              remove the cluster information
+//	GET    /clusters/{key}/nodes        nodes, databases, shards or endpoints
+//	GET    /clusters/{key}/databases
+//	GET    /clusters/{key}/shards
+//	GET    /clusters/{key}/endpoints
+//
+// Lists are returned as JSON or CSV depending on the Accept header or a format query parameter.
+// Other query parameters filter the list by the field with the same JSON name, such as
+// /clusters/{key}/shards?role=master&node=node:3. Repeating a parameter matches any of the values.
+type Server struct {
+	MaxUploadSize int64
+	mutex         sync.RWMutex
+	clusters      map[string]*clusterinfo.ClusterInfo
+	mux           *http.ServeMux
+}
+
+// serializer is implemented by each of the lists served
+type serializer interface {
+	JSON() (string, error)
+	CSV(skipHeaders bool) (string, error)
+}
+
+// New returns a server with no clusters stored
+func New() *Server {
+	s := &Server{
+		MaxUploadSize: DefaultMaxUploadSize,
+		clusters:      map[string]*clusterinfo.ClusterInfo{},
+		mux:           http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /clusters", s.listClusters)
+	s.mux.HandleFunc("POST /clusters/{key}", s.upload)
+	s.mux.HandleFunc("GET /clusters/{key}", s.getCluster)
+	s.mux.HandleFunc("DELETE /clusters/{key}", s.deleteCluster)
+	s.mux.HandleFunc("GET /clusters/{key}/nodes", func(w http.ResponseWriter, r *http.Request) {
+		serveList(s, w, r, func(c *clusterinfo.ClusterInfo) []*clusterinfo.Node { return c.Nodes },
+			func(nodes []*clusterinfo.Node) serializer { return clusterinfo.Nodes(nodes) })
+	})
+	s.mux.HandleFunc("GET /clusters/{key}/databases", func(w http.ResponseWriter, r *http.Request) {
+		serveList(s, w, r, func(c *clusterinfo.ClusterInfo) []*clusterinfo.Database { return c.Databases },
+			func(dbs []*clusterinfo.Database) serializer { d := clusterinfo.Databases(dbs); return &d })
+	})
+	s.mux.HandleFunc("GET /clusters/{key}/shards", func(w http.ResponseWriter, r *http.Request) {
+		serveList(s, w, r, func(c *clusterinfo.ClusterInfo) []*clusterinfo.Shard { return c.Shards },
+			func(shards []*clusterinfo.Shard) serializer { return clusterinfo.Shards(shards) })
+	})
+	s.mux.HandleFunc("GET /clusters/{key}/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		serveList(s, w, r, func(c *clusterinfo.ClusterInfo) []*clusterinfo.Endpoint { return c.Endpoints },
+			func(endpoints []*clusterinfo.Endpoint) serializer { return clusterinfo.Endpoints(endpoints) })
+	})
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Add stores cluster information, replacing any stored with the same key
+func (s *Server) Add(info *clusterinfo.ClusterInfo) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clusters[info.Key] = info
+}
+
+// Get returns the cluster information stored with the key or nil if there isn't any
+func (s *Server) Get(key string) *clusterinfo.ClusterInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.clusters[key]
+}
+
+// Keys returns the keys of the stored clusters in order
+func (s *Server) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0, len(s.clusters))
+	for key := range s.clusters {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (s *Server) listClusters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Keys())
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.MaxUploadSize)
+
+	var in io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, err := firstFile(r)
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		defer file.Close()
+		in = file
+	}
+
+	info, err := clusterinfo.NewClusterInfo(r.PathValue("key"), in)
+	if err != nil {
+		uploadError(w, err)
+		return
+	}
+	if len(info.Nodes) == 0 {
+		http.Error(w, "rlatool - no rladmin status output found in upload", http.StatusBadRequest)
+		return
+	}
+	s.Add(info)
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"key":       info.Key,
+		"nodes":     len(info.Nodes),
+		"databases": len(info.Databases),
+		"shards":    len(info.Shards),
+		"endpoints": len(info.Endpoints),
+	})
+}
+
+// uploadError writes the response for an upload which can't be read or parsed
+func uploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("rlatool - upload is larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// firstFile returns the first file in a multipart upload
+func firstFile(r *http.Request) (io.ReadCloser, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("rlatool - no file found in upload")
+		} else if err != nil {
+			return nil, err
+		}
+		if part.FileName() != "" {
+			return part, nil
+		}
+	}
+}
+
+func (s *Server) getCluster(w http.ResponseWriter, r *http.Request) {
+	if info := s.cluster(w, r); info != nil {
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// deleteCluster looks the cluster up and deletes it under one lock so a cluster uploaded
+// in between isn't deleted
+func (s *Server) deleteCluster(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	s.mutex.Lock()
+	_, ok := s.clusters[key]
+	delete(s.clusters, key)
+	s.mutex.Unlock()
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("cluster '%s' not found", key), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// cluster returns the cluster for the request, writing a not found response if there isn't one
+func (s *Server) cluster(w http.ResponseWriter, r *http.Request) *clusterinfo.ClusterInfo {
+	info := s.Get(r.PathValue("key"))
+	if info == nil {
+		http.Error(w, fmt.Sprintf("cluster '%s' not found", r.PathValue("key")), http.StatusNotFound)
+	}
+	return info
+}
+
+// serveList writes the filtered list in the format requested
+func serveList[T any](s *Server, w http.ResponseWriter, r *http.Request, list func(*clusterinfo.ClusterInfo) []*T, wrap func([]*T) serializer) {
+	info := s.cluster(w, r)
+	if info == nil {
+		return
+	}
+
+	contentType := negotiate(r)
+	if contentType == "" {
+		http.Error(w, "only JSON and CSV are available", http.StatusNotAcceptable)
+		return
+	}
+
+	items, err := filter(list(info), r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var out string
+	if contentType == contentCSV {
+		out, err = wrap(items).CSV(false)
+	} else {
+		out, err = wrap(items).JSON()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	_, _ = io.WriteString(w, out)
+}
+
+// negotiate returns the content type to respond with or an empty string if neither JSON nor CSV
+// is acceptable. The format query parameter overrides the Accept header.
+func negotiate(r *http.Request) string {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "json":
+		return contentJSON
+	case "csv":
+		return contentCSV
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return contentJSON
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		var contentType string
+		switch mediaType {
+		case contentJSON, "application/*", "*/*":
+			contentType = contentJSON
+		case contentCSV, "text/*":
+			contentType = contentCSV
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = contentType, q
+		}
+	}
+	return best
+}
+
+// filter returns the items matching every query parameter. Parameters are matched to fields by
+// their JSON name. Values are parsed as the field would be, so node=3 and node=node:3 both match
+// the node with id node:3, and comparisons are not case sensitive.
+func filter[T any](items []*T, query url.Values) ([]*T, error) {
+	itemType := reflect.TypeFor[T]()
+	type condition struct {
+		field  []int
+		values []reflect.Value
+	}
+
+	conditions := []condition{}
+	for name, values := range query {
+		if name == "format" {
+			continue
+		}
+		field, ok := fieldByJSONName(itemType, name)
+		if !ok {
+			return nil, fmt.Errorf("rlatool - unknown field '%s'", name)
+		}
+		c := condition{field: field.Index}
+		for _, value := range values {
+			parsed, err := parseValue(field.Type, value)
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, parsed)
+		}
+		conditions = append(conditions, c)
+	}
+
+	found := []*T{}
+	for _, item := range items {
+		v := reflect.ValueOf(item).Elem()
+		matched := true
+		for _, c := range conditions {
+			fieldValue := v.FieldByIndex(c.field)
+			if !slices.ContainsFunc(c.values, func(want reflect.Value) bool { return equal(fieldValue, want) }) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			found = append(found, item)
+		}
+	}
+	return found, nil
+}
+
+// fieldByJSONName finds an exported field, including those of embedded structs, by its JSON name
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, field := range reflect.VisibleFields(t) {
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag != "-" && strings.EqualFold(tag, name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// parseValue converts a query parameter value to the type of a field
+func parseValue(t reflect.Type, value string) (reflect.Value, error) {
+	parsed := reflect.New(t)
+	if unmarshaler, ok := parsed.Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(value)); err != nil {
+			return reflect.Value{}, fmt.Errorf("rlatool - unable to parse '%s' as %s: %w", value, t, err)
+		}
+		return parsed.Elem(), nil
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		parsed.Elem().SetString(value)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		parsed.Elem().SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(value, 10, t.Bits())
+		parsed.Elem().SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(value, 10, t.Bits())
+		parsed.Elem().SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(value, t.Bits())
+		parsed.Elem().SetFloat(f)
+	default:
+		return reflect.Value{}, fmt.Errorf("rlatool - unable to filter on values of type %s", t)
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("rlatool - unable to parse '%s' as %s: %w", value, t, err)
+	}
+	return parsed.Elem(), nil
+}
+
+// equal compares values of the same type, ignoring case for strings
+func equal(a, b reflect.Value) bool {
+	if a.Kind() == reflect.String {
+		return strings.EqualFold(a.String(), b.String())
+	}
+	if marshaler, ok := a.Interface().(encoding.TextMarshaler); ok {
+		left, err := marshaler.MarshalText()
+		if err == nil {
+			right, err := b.Interface().(encoding.TextMarshaler).MarshalText()
+			return err == nil && bytes.EqualFold(left, right)
+		}
+	}
+	if !a.Comparable() {
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+	return a.Equal(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	out, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentJSON)
+	w.WriteHeader(status)
+	_, _ = w.Write(out)
+}
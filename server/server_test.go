@@ -0,0 +1,128 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadServer(t *testing.T) *Server {
+	data, err := os.ReadFile("../testdata/node_1.rladmin")
+	assert.Nil(t, err)
+
+	s := New()
+	response := request(t, s, http.MethodPost, "/clusters/node1", "text/plain", bytes.NewReader(data), "")
+	assert.Equal(t, http.StatusCreated, response.Code)
+	return s
+}
+
+func request(t *testing.T, s *Server, method, target, contentType string, body *bytes.Reader, accept string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body == nil {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, body)
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestUpload(t *testing.T) {
+	s := loadServer(t)
+	assert.Equal(t, []string{"node1"}, s.Keys())
+	assert.Len(t, s.Get("node1").Nodes, 13)
+
+	data, err := os.ReadFile("../testdata/node_2.rladmin")
+	assert.Nil(t, err)
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	assert.Nil(t, form.WriteField("comment", "ignored"))
+	part, err := form.CreateFormFile("file", "rladmin_status.txt")
+	assert.Nil(t, err)
+	_, _ = part.Write(data)
+	assert.Nil(t, form.Close())
+
+	response := request(t, s, http.MethodPost, "/clusters/node2", form.FormDataContentType(), bytes.NewReader(body.Bytes()), "")
+	if assert.Equal(t, http.StatusCreated, response.Code) {
+		summary := map[string]any{}
+		assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &summary))
+		assert.Equal(t, float64(60), summary["shards"])
+	}
+	assert.Equal(t, []string{"node1", "node2"}, s.Keys())
+
+	response = request(t, s, http.MethodPost, "/clusters/broken", "text/plain", bytes.NewReader([]byte("nothing to see")), "")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	s.MaxUploadSize = 1024
+	response = request(t, s, http.MethodPost, "/clusters/large", "text/plain", bytes.NewReader(data), "")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.Code)
+	response = request(t, s, http.MethodPost, "/clusters/large", form.FormDataContentType(), bytes.NewReader(body.Bytes()), "")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.Code)
+
+	response = request(t, s, http.MethodDelete, "/clusters/node2", "", nil, "")
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	assert.Nil(t, s.Get("node2"))
+	response = request(t, s, http.MethodDelete, "/clusters/node2", "", nil, "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestLists(t *testing.T) {
+	s := loadServer(t)
+
+	response := request(t, s, http.MethodGet, "/clusters/node1/shards?dbId=10567021", "", nil, "")
+	if assert.Equal(t, http.StatusOK, response.Code) {
+		assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+		shards := []map[string]any{}
+		assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &shards))
+		assert.Len(t, shards, 2)
+	}
+
+	response = request(t, s, http.MethodGet, "/clusters/node1/shards?dbId=db:10567021&role=MASTER", "", nil, "text/csv, application/json;q=0.5")
+	if assert.Equal(t, http.StatusOK, response.Code) {
+		assert.Equal(t, "text/csv", response.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(response.Body.String()), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.Contains(t, lines[1], "redis:5")
+		}
+	}
+
+	response = request(t, s, http.MethodGet, "/clusters/node1/nodes?nodeId=3&nodeId=node:7&format=json", "", nil, "text/csv")
+	if assert.Equal(t, http.StatusOK, response.Code) {
+		nodes := []map[string]any{}
+		assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &nodes))
+		assert.Len(t, nodes, 2)
+	}
+
+	response = request(t, s, http.MethodGet, "/clusters/node1/databases?name=sudan-02", "", nil, "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = request(t, s, http.MethodGet, "/clusters/node1/endpoints", "", nil, "")
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	assert.Equal(t, http.StatusBadRequest, request(t, s, http.MethodGet, "/clusters/node1/shards?colour=red", "", nil, "").Code)
+	assert.Equal(t, http.StatusBadRequest, request(t, s, http.MethodGet, "/clusters/node1/shards?node=x", "", nil, "").Code)
+	// an empty memory size is a bad request, not a crash
+	for _, field := range []string{"usedMemory", "ramFrag"} {
+		response = request(t, s, http.MethodGet, "/clusters/node1/shards?"+field+"=", "", nil, "")
+		assert.Equal(t, http.StatusBadRequest, response.Code, field)
+		assert.Contains(t, response.Body.String(), "empty memory size")
+	}
+	assert.Equal(t, http.StatusNotAcceptable, request(t, s, http.MethodGet, "/clusters/node1/shards", "", nil, "application/xml").Code)
+	assert.Equal(t, http.StatusNotFound, request(t, s, http.MethodGet, "/clusters/other/shards", "", nil, "").Code)
+}
@@ -5,42 +5,40 @@ Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
 package clusterinfo
 
 import (
-	"bytes"
 	"cmp"
 	"encoding/json"
 	"slices"
 	"time"
 
 	"github.com/gocarina/gocsv"
-	"github.com/goslogan/fw"
 )
 
 type Shard struct {
-	Key            string       `columh:"-" json:"key" csv:"key"`
-	Id             string       `column:"ID" json:"id" csv:"shardid"`
-	DBId           string       `column:"DB:ID" json:"dbId" csv:"dbid"`
-	Name           string       `column:"NAME" json:"name" csv:"name"`
-	Node           string       `column:"NODE" json:"node" csv:"node"`
-	Role           string       `column:"ROLE" json:"role" csv:"role"`
-	Slots          string       `column:"SLOTS" json:"slots" csv:"slots"`
-	UsedMemory     RAMFloat     `column:"USED_MEMORY" json:"usedMemory" csv:"usedMemory"`
-	BackupProgress string       `column:"BACKUP_PROGRESS" ßjson:"backupProgress" csv:"backupProgress"`
-	RAMFrag        RAMFloat     `column:"RAM_FRAG" json:"ramFrag" csv:"ramFrag"`
-	WatchdogStatus string       `column:"WATCHDOG_STATUS" json:"watchdogStatus" csv:"watchdogStatus"`
-	Status         string       `column:"STATUS" json:"status" csv:"status"`
-	TimeStamp      time.Time    `json:"timeStamp" csv:"timeStamp" column:"-"`
-	parent         *ClusterInfo `csv:"-" json:"-"`
+	Key            string         `columh:"-" json:"key" csv:"key"`
+	Id             ShardID        `column:"ID" json:"id" csv:"shardid"`
+	DBId           DBID           `column:"DB:ID" json:"dbId" csv:"dbid"`
+	Name           string         `column:"NAME" json:"name" csv:"name"`
+	NodeId         NodeID         `column:"NODE" json:"node" csv:"node"`
+	Role           ShardRole      `column:"ROLE" json:"role" csv:"role"`
+	Slots          SlotRanges     `column:"SLOTS" json:"slots" csv:"slots"`
+	UsedMemory     RAMFloat       `column:"USED_MEMORY" json:"usedMemory" csv:"usedMemory"`
+	BackupProgress string         `column:"BACKUP_PROGRESS" ßjson:"backupProgress" csv:"backupProgress"`
+	RAMFrag        RAMFloat       `column:"RAM_FRAG" json:"ramFrag" csv:"ramFrag"`
+	WatchdogStatus WatchdogStatus `column:"WATCHDOG_STATUS" json:"watchdogStatus" csv:"watchdogStatus"`
+	Status         ShardStatus    `column:"STATUS" json:"status" csv:"status"`
+	TimeStamp      time.Time      `json:"timeStamp" csv:"timeStamp" column:"-"`
+	parent         *ClusterInfo   `csv:"-" json:"-"`
 }
 
 type Shards []*Shard
 
 func (c *Chunks) ParseShards(parent *ClusterInfo) (Shards, error) {
-	shards := Shards{}
-
-	decoder := fw.NewDecoder(bytes.NewReader(c.Shards))
-	decoder.IgnoreEmptyRecords = true
+	shards, _, err := c.parseShards(parent, false)
+	return shards, err
+}
 
-	err := decoder.Decode(&shards)
+func (c *Chunks) parseShards(parent *ClusterInfo, lenient bool) (Shards, ParseIssues, error) {
+	shards, issues, err := decodeChunk[Shard](c, ChunkShards, c.Shards, lenient)
 	if err == nil {
 		for _, s := range shards {
 			s.parent = parent
@@ -49,7 +47,7 @@ func (c *Chunks) ParseShards(parent *ClusterInfo) (Shards, error) {
 		}
 	}
 
-	return shards, err
+	return shards, issues, err
 }
 
 func (s Shards) CSV(skipHeaders bool) (string, error) {
@@ -70,7 +68,7 @@ func (s Shards) JSON() (string, error) {
 
 // ForDB returns all the shards for a given database, sorted in
 // Id order
-func (s Shards) ForDB(id string) Shards {
+func (s Shards) ForDB(id DBID) Shards {
 	ds := make(Shards, 0)
 	for _, shard := range s {
 		if shard.DBId == id {
@@ -85,3 +83,30 @@ func (s Shards) ForDB(id string) Shards {
 	return ds
 
 }
+
+// ShardPair is a master shard and the replica serving the same slots.
+type ShardPair struct {
+	Master  *Shard
+	Replica *Shard
+}
+
+// Pairs matches every master shard with the replica serving the same slots
+// for the same database. Replica is nil if there isn't one.
+func (s Shards) Pairs() []ShardPair {
+	pairs := []ShardPair{}
+	replicas := map[string]*Shard{}
+
+	for _, shard := range s {
+		if shard.Role == ShardRoleReplica {
+			replicas[shard.DBId.String()+"/"+shard.Slots.String()] = shard
+		}
+	}
+
+	for _, shard := range s {
+		if shard.Role == ShardRoleMaster {
+			pairs = append(pairs, ShardPair{Master: shard, Replica: replicas[shard.DBId.String()+"/"+shard.Slots.String()]})
+		}
+	}
+
+	return pairs
+}
@@ -0,0 +1,218 @@
+/*
+simulate.go predicts the effect of losing one or more nodes from the cluster
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding/json"
+)
+
+// NodeFailure is the set of node ids to fail in a simulation
+type NodeFailure []NodeID
+
+// Promotion is a replica which would be promoted after its master was lost
+type Promotion struct {
+	DBId     DBID    `json:"dbId"`
+	Name     string  `json:"name"`
+	Master   ShardID `json:"master"`
+	Replica  ShardID `json:"replica"`
+	FromNode NodeID  `json:"fromNode"`
+	ToNode   NodeID  `json:"toNode"`
+}
+
+// ShardLoss is a shard which would be lost without a surviving copy being
+// promoted in its place.
+type ShardLoss struct {
+	DBId  DBID       `json:"dbId"`
+	Name  string     `json:"name"`
+	Shard ShardID    `json:"shard"`
+	Node  NodeID     `json:"node"`
+	Slots SlotRanges `json:"slots"`
+}
+
+// RecreatedReplica is a replica which would be created on a surviving node to replace one
+// which was promoted or lost. Node is zero if no surviving node has the RAM and a free shard.
+type RecreatedReplica struct {
+	DBId   DBID     `json:"dbId"`
+	Name   string   `json:"name"`
+	Master ShardID  `json:"master"`
+	Node   NodeID   `json:"node"`
+	Size   RAMFloat `json:"size"`
+}
+
+// NodeState is the predicted state of a surviving node after failover and the recreation of
+// replicas. FreeRAM is the free RAM left once the recreated replicas have been placed.
+type NodeState struct {
+	Id       NodeID   `json:"id"`
+	Masters  uint16   `json:"masters"`
+	Replicas uint16   `json:"replicas"`
+	Shards   uint16   `json:"shards"`
+	FreeRAM  RAMFloat `json:"freeRAM"`
+	MaxRAM   RAMFloat `json:"maxRAM"`
+}
+
+// SimulationResult describes the predicted outcome of a node failure. A replica is recreated
+// for every promoted master and every master which lost its replica, as replica high
+// availability would. Each is placed on the surviving node with the most free RAM which has
+// a free shard and doesn't hold its master, preferring a node in a different rack.
+type SimulationResult struct {
+	Failed            []NodeID           `json:"failed"`
+	Promotions        []Promotion        `json:"promotions"`
+	DataLoss          []ShardLoss        `json:"dataLoss"`
+	ReplicasLost      []ShardLoss        `json:"replicasLost"`
+	Recreated         []RecreatedReplica `json:"recreated"`
+	Nodes             []*NodeState       `json:"nodes"`
+	ClusterMasterLost bool               `json:"clusterMasterLost"`
+	QuorumLost        bool               `json:"quorumLost"`
+}
+
+// Simulate predicts the result of the nodes in failure failing at the same time.
+func (c *ClusterInfo) Simulate(failure NodeFailure) *SimulationResult {
+	result := &SimulationResult{
+		Failed:       failure,
+		Promotions:   []Promotion{},
+		DataLoss:     []ShardLoss{},
+		ReplicasLost: []ShardLoss{},
+		Recreated:    []RecreatedReplica{},
+		Nodes:        []*NodeState{},
+	}
+
+	failed := map[NodeID]bool{}
+	for _, id := range failure {
+		failed[id] = true
+	}
+
+	// the role of each surviving shard after failover
+	roles := map[*Shard]ShardRole{}
+	for _, shard := range c.Shards {
+		roles[shard] = shard.Role
+	}
+
+	// masters which need a new replica, sized as the master
+	unreplicated := []*Shard{}
+
+	for _, db := range c.Databases {
+		for _, pair := range db.Shards().Pairs() {
+			masterFailed := failed[pair.Master.NodeId]
+			replicaFailed := pair.Replica != nil && failed[pair.Replica.NodeId]
+
+			switch {
+			case masterFailed && pair.Replica != nil && !replicaFailed:
+				roles[pair.Replica] = ShardRoleMaster
+				result.Promotions = append(result.Promotions, Promotion{
+					DBId:     db.Id,
+					Name:     db.Name,
+					Master:   pair.Master.Id,
+					Replica:  pair.Replica.Id,
+					FromNode: pair.Master.NodeId,
+					ToNode:   pair.Replica.NodeId,
+				})
+				unreplicated = append(unreplicated, pair.Replica)
+			case masterFailed:
+				result.DataLoss = append(result.DataLoss, shardLoss(db, pair.Master))
+			case replicaFailed:
+				result.ReplicasLost = append(result.ReplicasLost, shardLoss(db, pair.Replica))
+				unreplicated = append(unreplicated, pair.Master)
+			}
+		}
+	}
+
+	states := map[NodeID]*NodeState{}
+	candidates := Nodes{}
+	for _, node := range c.Nodes {
+		if !failed[node.Id] {
+			state := &NodeState{Id: node.Id, FreeRAM: node.RedisRAM.Free, MaxRAM: node.RedisRAM.Max}
+			states[node.Id] = state
+			result.Nodes = append(result.Nodes, state)
+			if node.Status.IsHealthy() && !node.Quorum {
+				candidates = append(candidates, node)
+			}
+		}
+	}
+
+	for shard, role := range roles {
+		if state, ok := states[shard.NodeId]; ok {
+			state.Shards++
+			switch role {
+			case ShardRoleMaster:
+				state.Masters++
+			case ShardRoleReplica:
+				state.Replicas++
+			}
+		}
+	}
+
+	for _, master := range unreplicated {
+		recreated := RecreatedReplica{DBId: master.DBId, Master: master.Id, Size: master.UsedMemory}
+		if db := master.Database(); db != nil {
+			recreated.Name = db.Name
+		}
+		if node := placeReplica(master, candidates, states); node != nil {
+			recreated.Node = node.Id
+			state := states[node.Id]
+			state.FreeRAM -= master.UsedMemory
+			state.Shards++
+			state.Replicas++
+		}
+		result.Recreated = append(result.Recreated, recreated)
+	}
+
+	if c.Cluster != nil {
+		result.ClusterMasterLost = failed[c.Cluster.MasterNode]
+	}
+	result.QuorumLost = len(result.Nodes)*2 <= len(c.Nodes)
+
+	return result
+}
+
+// placeReplica returns the node a new replica of master would be placed on or nil if there
+// isn't one with the RAM and a free shard.
+func placeReplica(master *Shard, candidates Nodes, states map[NodeID]*NodeState) *Node {
+	var rack string
+	if node := master.Node(); node != nil {
+		rack = node.RackId
+	}
+
+	var best *Node
+	for _, node := range candidates {
+		state := states[node.Id]
+		if node.Id == master.NodeId || state.Shards >= node.ShardUsage.Max || state.FreeRAM < master.UsedMemory {
+			continue
+		}
+		if best == nil {
+			best = node
+			continue
+		}
+		if rack != "" {
+			if otherRack := node.RackId != rack; otherRack != (best.RackId != rack) {
+				if otherRack {
+					best = node
+				}
+				continue
+			}
+		}
+		if state.FreeRAM > states[best.Id].FreeRAM {
+			best = node
+		}
+	}
+	return best
+}
+
+func shardLoss(db *Database, shard *Shard) ShardLoss {
+	return ShardLoss{
+		DBId:  db.Id,
+		Name:  db.Name,
+		Shard: shard.Id,
+		Node:  shard.NodeId,
+		Slots: shard.Slots,
+	}
+}
+
+func (r *SimulationResult) JSON() (string, error) {
+	if out, err := json.Marshal(r); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
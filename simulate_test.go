@@ -0,0 +1,54 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulate(t *testing.T) {
+	info, err := NewClusterInfo("test", bytes.NewReader(rsOutput))
+	assert.Nil(t, err)
+
+	result := info.Simulate(NodeFailure{13})
+	assert.Len(t, result.Promotions, 2)
+	assert.Empty(t, result.DataLoss)
+	assert.Len(t, result.Nodes, 30)
+	assert.False(t, result.QuorumLost)
+	assert.False(t, result.ClusterMasterLost)
+	for _, state := range result.Nodes {
+		if state.Id == 28 {
+			assert.Equal(t, uint16(1), state.Masters)
+			assert.Equal(t, uint16(1), state.Replicas)
+		}
+	}
+
+	// a replica is recreated for each promoted master and takes RAM from its new node
+	if assert.Len(t, result.Recreated, 2) {
+		recreated := RAMFloat(0)
+		for _, replica := range result.Recreated {
+			assert.NotZero(t, replica.Node)
+			assert.NotEqual(t, info.Shard(replica.Master).NodeId, replica.Node)
+			recreated += replica.Size
+		}
+		used := RAMFloat(0)
+		for _, state := range result.Nodes {
+			used += info.Node(state.Id).RedisRAM.Free - state.FreeRAM
+		}
+		assert.InDelta(t, float64(recreated), float64(used), 0.0001)
+	}
+
+	result = info.Simulate(NodeFailure{13, 28, 1})
+	assert.Len(t, result.Promotions, 1)
+	assert.True(t, result.ClusterMasterLost)
+	if assert.Len(t, result.DataLoss, 1) {
+		assert.Equal(t, ShardLoss{DBId: 10, Name: "REDISCACHE001", Shard: 2, Node: 13, Slots: SlotRanges{{From: 546, To: 1091}}}, result.DataLoss[0])
+	}
+	if assert.Len(t, result.ReplicasLost, 1) {
+		assert.Equal(t, ShardID(51), result.ReplicasLost[0].Shard)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+slots.go provides hash slot ranges for shards and validation of slot coverage
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HashSlots is the number of hash slots a database's master shards must cover
+const HashSlots = 16384
+
+// SlotRange is an inclusive range of hash slots
+type SlotRange struct {
+	From uint16
+	To   uint16
+}
+
+// SlotRanges holds the hash slots served by a shard
+type SlotRanges []SlotRange
+
+// SlotCoverage reports problems with the slot assignment of a database
+type SlotCoverage struct {
+	DBId DBID   `json:"dbId"`
+	Name string `json:"name"`
+	// Gaps are slots not served by any master
+	Gaps SlotRanges `json:"gaps"`
+	// Overlaps are slots served by more than one master
+	Overlaps SlotRanges `json:"overlaps"`
+	// Unmatched are replicas whose slots don't match any master
+	Unmatched []ShardID `json:"unmatched"`
+	// Unreplicated are masters without a replica in a replicated database
+	Unreplicated []ShardID `json:"unreplicated"`
+}
+
+type SlotReport []*SlotCoverage
+
+func (r SlotRange) String() string {
+	if r.From == r.To {
+		return strconv.Itoa(int(r.From))
+	}
+	return fmt.Sprintf("%d-%d", r.From, r.To)
+}
+
+func (s SlotRanges) String() string {
+	parts := make([]string, len(s))
+	for n, r := range s {
+		parts[n] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Count returns the number of slots in the ranges
+func (s SlotRanges) Count() int {
+	count := 0
+	for _, r := range s {
+		count += int(r.To) - int(r.From) + 1
+	}
+	return count
+}
+
+// Contains returns true if the slot is in one of the ranges
+func (s SlotRanges) Contains(slot uint16) bool {
+	for _, r := range s {
+		if slot >= r.From && slot <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SlotRanges) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *SlotRanges) UnmarshalText(input []byte) error {
+	ranges := SlotRanges{}
+	text := strings.TrimSpace(string(input))
+
+	if text == "" {
+		*s = ranges
+		return nil
+	}
+
+	for _, part := range strings.Split(text, ",") {
+		from, to, found := strings.Cut(strings.TrimSpace(part), "-")
+		if !found {
+			to = from
+		}
+
+		start, err := parseSlot(from)
+		if err != nil {
+			return fmt.Errorf(errorString, part, "slot range", err)
+		}
+		end, err := parseSlot(to)
+		if err != nil {
+			return fmt.Errorf(errorString, part, "slot range", err)
+		}
+		if end < start {
+			return fmt.Errorf(errorString, part, "slot range", fmt.Errorf("range ends before it starts"))
+		}
+		ranges = append(ranges, SlotRange{From: start, To: end})
+	}
+
+	*s = ranges
+	return nil
+}
+
+func parseSlot(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	if v >= HashSlots {
+		return 0, fmt.Errorf("slot %d out of range", v)
+	}
+	return uint16(v), nil
+}
+
+// ValidateSlots checks that the master shards of every database cover all the hash slots
+// exactly once and that every replica serves the same slots as a master. Only databases
+// with problems are returned.
+func (c *ClusterInfo) ValidateSlots() SlotReport {
+	report := SlotReport{}
+	for _, db := range c.Databases {
+		if coverage := db.ValidateSlots(); coverage != nil {
+			report = append(report, coverage)
+		}
+	}
+	return report
+}
+
+// ValidateSlots checks the slot assignment of the database's shards. nil is returned
+// if no problems are found.
+func (db *Database) ValidateSlots() *SlotCoverage {
+	coverage := &SlotCoverage{
+		DBId:         db.Id,
+		Name:         db.Name,
+		Unmatched:    []ShardID{},
+		Unreplicated: []ShardID{},
+	}
+	counts := make([]int, HashSlots)
+	shards := db.Shards()
+	matched := map[*Shard]bool{}
+
+	for _, pair := range shards.Pairs() {
+		for _, r := range pair.Master.Slots {
+			for slot := int(r.From); slot <= int(r.To); slot++ {
+				counts[slot]++
+			}
+		}
+		if pair.Replica != nil {
+			matched[pair.Replica] = true
+		} else if db.Replication.Enabled() {
+			coverage.Unreplicated = append(coverage.Unreplicated, pair.Master.Id)
+		}
+	}
+
+	for _, shard := range shards {
+		if shard.Role == ShardRoleReplica && !matched[shard] {
+			coverage.Unmatched = append(coverage.Unmatched, shard.Id)
+		}
+	}
+
+	coverage.Gaps = slotRuns(counts, func(n int) bool { return n == 0 })
+	coverage.Overlaps = slotRuns(counts, func(n int) bool { return n > 1 })
+
+	if len(coverage.Gaps)+len(coverage.Overlaps)+len(coverage.Unmatched)+len(coverage.Unreplicated) == 0 {
+		return nil
+	}
+	return coverage
+}
+
+// slotRuns returns the ranges of slots whose count matches
+func slotRuns(counts []int, match func(int) bool) SlotRanges {
+	runs := SlotRanges{}
+	start := -1
+	for slot := 0; slot <= len(counts); slot++ {
+		if slot < len(counts) && match(counts[slot]) {
+			if start < 0 {
+				start = slot
+			}
+		} else if start >= 0 {
+			runs = append(runs, SlotRange{From: uint16(start), To: uint16(slot - 1)})
+			start = -1
+		}
+	}
+	return runs
+}
+
+func (r SlotReport) JSON() (string, error) {
+	if out, err := json.Marshal(r); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
@@ -0,0 +1,285 @@
+/*
+sqlexport.go exports cluster information into a SQLite database
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package sqlexport
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/goslogan/clusterinfo"
+	_ "modernc.org/sqlite"
+)
+
+// TimeFormat is the format of the time_stamp column. It is understood by the SQLite date
+// functions and sorts in time order.
+const TimeFormat = "2006-01-02 15:04:05.000"
+
+// Every table has the key and time stamp of the snapshot as the leading columns of its primary
+// key so snapshots of any number of clusters can be kept in one database.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS nodes (
+		key TEXT NOT NULL,
+		time_stamp TEXT NOT NULL,
+		id INTEGER NOT NULL,
+		role TEXT,
+		address TEXT,
+		external_address TEXT,
+		host_name TEXT,
+		overbooking_depth REAL,
+		masters INTEGER,
+		replicas INTEGER,
+		shards_in_use INTEGER,
+		max_shards INTEGER,
+		cores INTEGER,
+		free_ram REAL,
+		max_ram REAL,
+		free_provisional_ram REAL,
+		max_provisional_ram REAL,
+		version TEXT,
+		sha TEXT,
+		rack_id TEXT,
+		status TEXT,
+		quorum INTEGER,
+		is_local INTEGER,
+		PRIMARY KEY (key, time_stamp, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS databases (
+		key TEXT NOT NULL,
+		time_stamp TEXT NOT NULL,
+		id INTEGER NOT NULL,
+		name TEXT,
+		type TEXT,
+		status TEXT,
+		shards INTEGER,
+		placement TEXT,
+		replication TEXT,
+		persistence TEXT,
+		exec_state TEXT,
+		exec_state_machine TEXT,
+		backup_progress TEXT,
+		missing_backup_time TEXT,
+		redis_version TEXT,
+		PRIMARY KEY (key, time_stamp, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS database_endpoints (
+		key TEXT NOT NULL,
+		time_stamp TEXT NOT NULL,
+		db_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		endpoint TEXT,
+		PRIMARY KEY (key, time_stamp, db_id, position),
+		FOREIGN KEY (key, time_stamp, db_id) REFERENCES databases (key, time_stamp, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS shards (
+		key TEXT NOT NULL,
+		time_stamp TEXT NOT NULL,
+		id INTEGER NOT NULL,
+		db_id INTEGER NOT NULL,
+		name TEXT,
+		node_id INTEGER NOT NULL,
+		role TEXT,
+		slots TEXT,
+		used_memory REAL,
+		backup_progress TEXT,
+		ram_frag REAL,
+		watchdog_status TEXT,
+		status TEXT,
+		PRIMARY KEY (key, time_stamp, id),
+		FOREIGN KEY (key, time_stamp, db_id) REFERENCES databases (key, time_stamp, id),
+		FOREIGN KEY (key, time_stamp, node_id) REFERENCES nodes (key, time_stamp, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS endpoints (
+		key TEXT NOT NULL,
+		time_stamp TEXT NOT NULL,
+		db_id INTEGER NOT NULL,
+		number INTEGER NOT NULL,
+		node_id INTEGER NOT NULL,
+		name TEXT,
+		role TEXT,
+		ssl INTEGER,
+		watchdog_status TEXT,
+		PRIMARY KEY (key, time_stamp, db_id, number, node_id),
+		FOREIGN KEY (key, time_stamp, db_id) REFERENCES databases (key, time_stamp, id),
+		FOREIGN KEY (key, time_stamp, node_id) REFERENCES nodes (key, time_stamp, id)
+	)`,
+}
+
+// Exporter writes cluster information to a SQLite database
+type Exporter struct {
+	db *sql.DB
+}
+
+// Orphan is a shard or endpoint which wasn't exported because its node or database isn't in
+// the snapshot. Lenient parsing can drop the rows they refer to.
+type Orphan struct {
+	Key       string    `json:"key"`
+	TimeStamp time.Time `json:"timeStamp"`
+	Table     string    `json:"table"`
+	Id        string    `json:"id"`
+	Missing   string    `json:"missing"`
+}
+
+type Orphans []Orphan
+
+// Open opens or creates the SQLite database file and creates the tables if needed. Foreign
+// keys are enforced.
+func Open(name string) (*Exporter, error) {
+	dsn := &url.URL{Scheme: "file", Path: name, RawQuery: "_pragma=foreign_keys(1)"}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	e, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// New uses an open SQLite database, creating the tables if needed
+func New(db *sql.DB) (*Exporter, error) {
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, fmt.Errorf("rlatool - unable to create tables: %w", err)
+		}
+	}
+	return &Exporter{db: db}, nil
+}
+
+// DB returns the database, for queries
+func (e *Exporter) DB() *sql.DB {
+	return e.db
+}
+
+func (e *Exporter) Close() error {
+	return e.db.Close()
+}
+
+// Export adds the cluster information to the database. Each snapshot is written in its own
+// transaction and a snapshot which has already been exported is rejected. Shards and
+// endpoints whose node or database isn't in the snapshot are skipped and returned as orphans.
+func (e *Exporter) Export(infos ...*clusterinfo.ClusterInfo) (Orphans, error) {
+	orphans := Orphans{}
+	for _, info := range infos {
+		found, err := e.export(info)
+		if err != nil {
+			return nil, fmt.Errorf("rlatool - unable to export '%s' at %s: %w", info.Key, info.TimeStamp.Format(time.RFC3339), err)
+		}
+		orphans = append(orphans, found...)
+	}
+	return orphans, nil
+}
+
+func (e *Exporter) export(info *clusterinfo.ClusterInfo) (Orphans, error) {
+	tx, err := e.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	key, ts := info.Key, info.TimeStamp.UTC().Format(TimeFormat)
+
+	nodes := make([][]any, len(info.Nodes))
+	for n, node := range info.Nodes {
+		nodes[n] = []any{key, ts, uint64(node.Id), string(node.Role), node.Address.String(), node.ExternalAddress.String(),
+			node.HostName, float64(node.OverbookingDepth), node.Masters, node.Replicas, node.ShardUsage.InUse,
+			node.ShardUsage.Max, node.Cores, float64(node.RedisRAM.Free), float64(node.RedisRAM.Max),
+			float64(node.ProvisionalRAM.Free), float64(node.ProvisionalRAM.Max), node.Version, node.SHA, node.RackId,
+			string(node.Status), node.Quorum, node.IsLocal}
+	}
+
+	databases := make([][]any, len(info.Databases))
+	endpoints := [][]any{}
+	for n, db := range info.Databases {
+		databases[n] = []any{key, ts, uint64(db.Id), db.Name, db.Type, string(db.Status), db.MasterShards,
+			string(db.Placement), string(db.Replication), string(db.Persistence), string(db.ExecState),
+			db.ExecStateMachine, db.BackupProgress, db.MissingBackupTime, db.RedisVersion}
+		for position, endpoint := range db.Endpoint {
+			endpoints = append(endpoints, []any{key, ts, uint64(db.Id), position, endpoint})
+		}
+	}
+
+	orphans := Orphans{}
+	orphan := func(table, id string, missing fmt.Stringer) {
+		orphans = append(orphans, Orphan{Key: info.Key, TimeStamp: info.TimeStamp, Table: table, Id: id, Missing: missing.String()})
+	}
+
+	shards := [][]any{}
+	for _, shard := range info.Shards {
+		if info.Database(shard.DBId) == nil {
+			orphan("shards", shard.Id.String(), shard.DBId)
+			continue
+		}
+		if info.Node(shard.NodeId) == nil {
+			orphan("shards", shard.Id.String(), shard.NodeId)
+			continue
+		}
+		shards = append(shards, []any{key, ts, uint64(shard.Id), uint64(shard.DBId), shard.Name, uint64(shard.NodeId),
+			string(shard.Role), shard.Slots.String(), float64(shard.UsedMemory), shard.BackupProgress,
+			float64(shard.RAMFrag), string(shard.WatchdogStatus), string(shard.Status)})
+	}
+
+	bound := [][]any{}
+	for _, endpoint := range info.Endpoints {
+		if info.Database(endpoint.Id.DB) == nil {
+			orphan("endpoints", endpoint.Id.String(), endpoint.Id.DB)
+			continue
+		}
+		if info.Node(endpoint.NodeId) == nil {
+			orphan("endpoints", endpoint.Id.String(), endpoint.NodeId)
+			continue
+		}
+		bound = append(bound, []any{key, ts, uint64(endpoint.Id.DB), endpoint.Id.Number, uint64(endpoint.NodeId), endpoint.Name,
+			string(endpoint.Role), endpoint.SSL, string(endpoint.WatchdogStatus)})
+	}
+
+	// parents are inserted before the rows referring to them
+	for _, table := range []struct {
+		name    string
+		columns string
+		rows    [][]any
+	}{
+		{"nodes", "key, time_stamp, id, role, address, external_address, host_name, overbooking_depth, masters, replicas, " +
+			"shards_in_use, max_shards, cores, free_ram, max_ram, free_provisional_ram, max_provisional_ram, version, sha, " +
+			"rack_id, status, quorum, is_local", nodes},
+		{"databases", "key, time_stamp, id, name, type, status, shards, placement, replication, persistence, exec_state, " +
+			"exec_state_machine, backup_progress, missing_backup_time, redis_version", databases},
+		{"database_endpoints", "key, time_stamp, db_id, position, endpoint", endpoints},
+		{"shards", "key, time_stamp, id, db_id, name, node_id, role, slots, used_memory, backup_progress, ram_frag, " +
+			"watchdog_status, status", shards},
+		{"endpoints", "key, time_stamp, db_id, number, node_id, name, role, ssl, watchdog_status", bound},
+	} {
+		if err := insert(tx, table.name, table.columns, table.rows); err != nil {
+			return nil, err
+		}
+	}
+
+	return orphans, tx.Commit()
+}
+
+// insert adds rows to a table with a prepared statement
+func insert(tx *sql.Tx, table, columns string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(rows[0])), ", ")
+	statement, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, columns, placeholders))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, row := range rows {
+		if _, err := statement.Exec(row...); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return nil
+}
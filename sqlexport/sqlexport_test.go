@@ -0,0 +1,99 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package sqlexport
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/goslogan/clusterinfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadInfo(t *testing.T, key, name string) *clusterinfo.ClusterInfo {
+	in, err := os.Open(filepath.Join("..", "testdata", name))
+	assert.Nil(t, err)
+	defer in.Close()
+	info, err := clusterinfo.NewClusterInfo(key, in)
+	assert.Nil(t, err)
+	return info
+}
+
+func TestExport(t *testing.T) {
+	// characters with a meaning in URIs are escaped
+	name := filepath.Join(t.TempDir(), "clusters?#1.db")
+	e, err := Open(name)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	first := loadInfo(t, "node1", "node_1.rladmin")
+	second := loadInfo(t, "node1", "node_1.rladmin")
+	second.TimeStamp = second.TimeStamp.Add(time.Hour)
+	orphans, err := e.Export(first, second, loadInfo(t, "node2", "node_2.rladmin"))
+	assert.Nil(t, err)
+	assert.Empty(t, orphans)
+	_, err = e.Export(first)
+	assert.NotNil(t, err)
+	assert.Nil(t, e.Close())
+	_, err = os.Stat(name)
+	assert.Nil(t, err)
+
+	// reopened to check the rows were appended to the file
+	e, err = Open(name)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer e.Close()
+
+	var count int
+	assert.Nil(t, e.DB().QueryRow(`SELECT count(*) FROM nodes WHERE key = 'node1'`).Scan(&count))
+	assert.Equal(t, 26, count)
+	assert.Nil(t, e.DB().QueryRow(`SELECT count(DISTINCT time_stamp) FROM shards WHERE key = 'node1'`).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	var node string
+	var used float64
+	err = e.DB().QueryRow(`SELECT n.host_name, s.used_memory FROM shards s
+		JOIN nodes n ON n.key = s.key AND n.time_stamp = s.time_stamp AND n.id = s.node_id
+		JOIN databases d ON d.key = s.key AND d.time_stamp = s.time_stamp AND d.id = s.db_id
+		WHERE s.key = 'node1' AND s.id = 5 AND d.name = 'sudan-02' LIMIT 1`).Scan(&node, &used)
+	assert.Nil(t, err)
+	assert.Equal(t, "node7", node)
+	assert.InDelta(t, 10.33, used, 0.001)
+
+	assert.Nil(t, e.DB().QueryRow(`SELECT id FROM nodes WHERE key = 'node2' AND is_local`).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	_, err = e.DB().Exec(`INSERT INTO shards (key, time_stamp, id, db_id, node_id) VALUES ('node1', 'never', 1, 1, 1)`)
+	assert.NotNil(t, err)
+}
+
+func TestExportOrphans(t *testing.T) {
+	e, err := Open(filepath.Join(t.TempDir(), "clusters.db"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer e.Close()
+
+	// node:7 was dropped by a lenient parse
+	info := loadInfo(t, "node1", "node_1.rladmin")
+	shards, endpoints := len(info.Node(7).Shards()), len(info.Node(7).Endpoints())
+	info.Nodes = slices.DeleteFunc(info.Nodes, func(n *clusterinfo.Node) bool { return n.Id == 7 })
+	info.Reindex()
+
+	orphans, err := e.Export(info)
+	assert.Nil(t, err)
+	if assert.Len(t, orphans, shards+endpoints) && assert.NotZero(t, shards) {
+		assert.Equal(t, "shards", orphans[0].Table)
+		assert.Equal(t, "node:7", orphans[0].Missing)
+	}
+
+	var count int
+	assert.Nil(t, e.DB().QueryRow(`SELECT count(*) FROM shards`).Scan(&count))
+	assert.Equal(t, len(info.Shards)-shards, count)
+}
@@ -0,0 +1,247 @@
+/*
+supportpackage.go loads rladmin status output from Redis Enterprise support packages
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// rladmin status output is recognised by the CLUSTER NODES section header in the first
+	// few lines. Logs and scripts often mention the command itself.
+	statusMarker = regexp.MustCompile(`(?m)^CLUSTER NODES:\r?$`)
+	nodeDir      = regexp.MustCompile(`(?i)^node_?\d+$`)
+)
+
+const sniffLength = 4096
+
+// SupportPackage maps node names to the cluster information loaded from the
+// rladmin status output captured on that node.
+type SupportPackage map[string]*ClusterInfo
+
+// PackageIssue is a file in a support package which looked like rladmin status output but
+// was skipped because it couldn't be parsed or had no nodes
+type PackageIssue struct {
+	File string `json:"file"`
+	Err  error  `json:"-"`
+}
+
+type PackageIssues []PackageIssue
+
+func (i PackageIssue) Error() string {
+	if i.Err == nil {
+		return fmt.Sprintf("%s skipped", i.File)
+	}
+	return fmt.Sprintf("%s skipped: %v", i.File, i.Err)
+}
+
+func (i PackageIssue) Unwrap() error {
+	return i.Err
+}
+
+// packageLoader collects the rladmin status files found in a support package
+type packageLoader struct {
+	files  map[string]*ClusterInfo // keyed by path within the package
+	issues PackageIssues
+}
+
+// LoadSupportPackage loads every rladmin status file found in a support package. The path
+// can be either a support package archive (gzipped or plain tar) or a directory the
+// package has been extracted into. Archives nested inside the package (as generated for
+// each node) are searched too.
+//
+// Each file is keyed by the name of the node it was captured on. If more than one file is
+// found for a node, each of them is keyed by its path within the package instead, so the
+// keys don't depend on the order of the files. Files which can't be parsed or have no nodes
+// are skipped and returned as issues.
+func LoadSupportPackage(name string) (SupportPackage, PackageIssues, error) {
+	stat, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loader := newPackageLoader()
+
+	if stat.IsDir() {
+		err = loader.loadDirectory(name)
+	} else {
+		var f *os.File
+		if f, err = os.Open(name); err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		err = loader.loadArchive("", f)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return loader.supportPackage(), loader.issues, nil
+}
+
+// ReadSupportPackage loads every rladmin status file found in a support package archive
+// read from in. Files are keyed as for LoadSupportPackage.
+func ReadSupportPackage(in io.Reader) (SupportPackage, PackageIssues, error) {
+	loader := newPackageLoader()
+	if err := loader.loadArchive("", in); err != nil {
+		return nil, nil, err
+	}
+	return loader.supportPackage(), loader.issues, nil
+}
+
+func newPackageLoader() *packageLoader {
+	return &packageLoader{files: map[string]*ClusterInfo{}, issues: PackageIssues{}}
+}
+
+func (l *packageLoader) loadDirectory(root string) error {
+	return filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		rel, _ := filepath.Rel(root, name)
+		return l.loadFile(filepath.ToSlash(rel), f)
+	})
+}
+
+func (l *packageLoader) loadArchive(prefix string, in io.Reader) error {
+	reader := bufio.NewReader(in)
+
+	// support packages are normally gzipped but we accept plain tar files too
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		in = gz
+	} else {
+		in = reader
+	}
+
+	archive := tar.NewReader(in)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("rlatool - unable to read support package: %w", err)
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			if err := l.loadFile(path.Join(prefix, header.Name), archive); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// loadFile checks a file from the package and parses it if it contains rladmin status
+// output. Nested archives are loaded recursively.
+func (l *packageLoader) loadFile(name string, in io.Reader) error {
+	reader := bufio.NewReaderSize(in, sniffLength)
+	head, _ := reader.Peek(sniffLength)
+
+	if base, ok := archiveBase(name); ok {
+		return l.loadArchive(base+"/", reader)
+	}
+
+	if !statusMarker.Match(head) {
+		return nil
+	}
+
+	// files which only look like rladmin status output are skipped
+	info, err := NewClusterInfo(name, reader)
+	if err != nil {
+		l.issues = append(l.issues, PackageIssue{File: name, Err: err})
+	} else if len(info.Nodes) == 0 {
+		l.issues = append(l.issues, PackageIssue{File: name, Err: fmt.Errorf("rlatool - no nodes found")})
+	} else {
+		l.files[name] = info
+	}
+	return nil
+}
+
+// supportPackage keys each file by its node name, or by its path if the node name is shared
+func (l *packageLoader) supportPackage() SupportPackage {
+	counts := map[string]int{}
+	for name := range l.files {
+		counts[nodeName(name)]++
+	}
+
+	pkg := SupportPackage{}
+	for name, info := range l.files {
+		key := nodeName(name)
+		if counts[key] > 1 {
+			key = name
+		}
+		info.rekey(key)
+		pkg[key] = info
+	}
+	return pkg
+}
+
+// rekey changes the key of the cluster information and everything in it
+func (c *ClusterInfo) rekey(key string) {
+	c.Key = key
+	if c.Cluster != nil {
+		c.Cluster.Key = key
+	}
+	for _, node := range c.Nodes {
+		node.Key = key
+	}
+	for _, db := range c.Databases {
+		db.Key = key
+	}
+	for _, shard := range c.Shards {
+		shard.Key = key
+	}
+	for _, endpoint := range c.Endpoints {
+		endpoint.Key = key
+	}
+}
+
+// nodeName derives the name of the node from the path of an rladmin status file. The closest
+// directory named like a node is used. If there isn't one, the file name is used.
+func nodeName(name string) string {
+	parts := strings.Split(path.Clean(name), "/")
+	for n := len(parts) - 1; n >= 0; n-- {
+		for _, part := range strings.Split(parts[n], ".") {
+			if nodeDir.MatchString(part) {
+				return part
+			}
+		}
+	}
+
+	base := path.Base(name)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// archiveBase returns the name of an archive without its extension and true if
+// the name is that of an archive.
+func archiveBase(name string) (string, bool) {
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+	return name, false
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeArchive(t *testing.T, files map[string][]byte) []byte {
+	buffer := &bytes.Buffer{}
+	gz := gzip.NewWriter(buffer)
+	archive := tar.NewWriter(gz)
+
+	for name, data := range files {
+		assert.Nil(t, archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := archive.Write(data)
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, archive.Close())
+	assert.Nil(t, gz.Close())
+	return buffer.Bytes()
+}
+
+func TestSupportPackageArchive(t *testing.T) {
+	nested := makeArchive(t, map[string][]byte{"rladmin_status.txt": rsOutput})
+	corrupted := bytes.Replace(rladmin, []byte("10.10.21.5 "), []byte("10.10.21.X "), 1)
+	pkg, issues, err := ReadSupportPackage(bytes.NewReader(makeArchive(t, map[string][]byte{
+		"debuginfo/node_1/rladmin_status.txt": rladmin,
+		"debuginfo/node_1/other.log":          []byte("nothing to see here"),
+		"debuginfo/node_1/commands.sh":        []byte("#!/bin/sh\nrladmin status extra all\n"),
+		"debuginfo/node_1/status.log":         []byte("rladmin status\nCLUSTER NODES:\n"),
+		"debuginfo/node_3/rladmin_status.txt": corrupted,
+		"debuginfo/debuginfo.node_2.tar.gz":   nested,
+	})))
+
+	if assert.Nil(t, err) && assert.Len(t, pkg, 2) {
+		assert.Len(t, pkg["node_1"].Nodes, 13)
+		assert.Equal(t, "node_1", pkg["node_1"].Key)
+		assert.Equal(t, "node_1", pkg["node_1"].Nodes[0].Key)
+		assert.Len(t, pkg["node_2"].Shards, 60)
+	}
+
+	// the file with no nodes and the one which can't be parsed are reported
+	files := []string{}
+	for _, issue := range issues {
+		files = append(files, issue.File)
+	}
+	assert.ElementsMatch(t, []string{"debuginfo/node_1/status.log", "debuginfo/node_3/rladmin_status.txt"}, files)
+	var parseErr *ParseError
+	for _, issue := range issues {
+		if issue.File == "debuginfo/node_3/rladmin_status.txt" {
+			assert.ErrorAs(t, issue, &parseErr)
+		}
+	}
+}
+
+func TestSupportPackageSharedNode(t *testing.T) {
+	pkg, issues, err := ReadSupportPackage(bytes.NewReader(makeArchive(t, map[string][]byte{
+		"debuginfo/node_1/rladmin_status.txt":   rladmin,
+		"debuginfo/node_1/rladmin_status.1.txt": rladmin,
+	})))
+
+	// neither file takes the node name as that would depend on archive order
+	if assert.Nil(t, err) && assert.Empty(t, issues) && assert.Len(t, pkg, 2) {
+		assert.Nil(t, pkg["node_1"])
+		assert.Equal(t, "debuginfo/node_1/rladmin_status.1.txt", pkg["debuginfo/node_1/rladmin_status.1.txt"].Key)
+		assert.NotNil(t, pkg["debuginfo/node_1/rladmin_status.txt"])
+	}
+}
+
+func TestSupportPackageDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for node, data := range map[string][]byte{"node_1": rladmin, "node_2": rsOutput} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, node), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, node, "rladmin_status"), data, 0644))
+	}
+
+	pkg, issues, err := LoadSupportPackage(dir)
+	if assert.Nil(t, err) && assert.Empty(t, issues) && assert.Len(t, pkg, 2) {
+		assert.Len(t, pkg["node_2"].Databases, 1)
+	}
+}
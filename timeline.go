@@ -0,0 +1,295 @@
+/*
+timeline.go provides a history of cluster information snapshots and trend queries over it
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gocarina/gocsv"
+)
+
+// Timeline holds snapshots of the same cluster in TimeStamp order
+type Timeline struct {
+	Key       string
+	Snapshots []*ClusterInfo
+}
+
+// TrendPoint is the value of a measurement for an entity in one snapshot
+type TrendPoint struct {
+	Key       string    `json:"key" csv:"key"`
+	Id        string    `json:"id" csv:"id"`
+	TimeStamp time.Time `json:"timeStamp" csv:"timeStamp"`
+	Value     RAMFloat  `json:"value" csv:"value"`
+}
+
+type TrendPoints []*TrendPoint
+
+// Trend is a measurement for an entity over the timeline. Change is the difference between
+// the first and last values and Rate is the rate of change per hour, fitted over every point.
+type Trend struct {
+	Key    string      `json:"key" csv:"key"`
+	Id     string      `json:"id" csv:"id"`
+	First  time.Time   `json:"first" csv:"first"`
+	Last   time.Time   `json:"last" csv:"last"`
+	Change RAMFloat    `json:"change" csv:"change"`
+	Rate   RAMFloat    `json:"ratePerHour" csv:"ratePerHour"`
+	Points TrendPoints `json:"points" csv:"-"`
+}
+
+type Trends []*Trend
+
+// ShardMove records a shard found on a different node from the previous snapshot. The move
+// happened after After and before TimeStamp.
+type ShardMove struct {
+	Key       string    `json:"key" csv:"key"`
+	Shard     ShardID   `json:"shard" csv:"shard"`
+	DBId      DBID      `json:"dbId" csv:"dbId"`
+	From      NodeID    `json:"from" csv:"from"`
+	To        NodeID    `json:"to" csv:"to"`
+	After     time.Time `json:"after" csv:"after"`
+	TimeStamp time.Time `json:"timeStamp" csv:"timeStamp"`
+}
+
+type ShardMoves []*ShardMove
+
+// NewTimeline returns an empty timeline for the cluster with the given key
+func NewTimeline(key string) *Timeline {
+	return &Timeline{Key: key, Snapshots: []*ClusterInfo{}}
+}
+
+// Add adds snapshots to the timeline, keeping it in TimeStamp order. If any snapshot
+// is nil or for a different key, none are added.
+func (t *Timeline) Add(snapshots ...*ClusterInfo) error {
+	for _, snapshot := range snapshots {
+		if snapshot == nil {
+			return fmt.Errorf("rlatool - a nil snapshot can't be added to the timeline for '%s'", t.Key)
+		}
+		if snapshot.Key != t.Key {
+			return fmt.Errorf("rlatool - snapshot for '%s' can't be added to the timeline for '%s'", snapshot.Key, t.Key)
+		}
+	}
+
+	t.Snapshots = append(t.Snapshots, snapshots...)
+
+	sort.SliceStable(t.Snapshots, func(i, j int) bool {
+		return t.Snapshots[i].TimeStamp.Before(t.Snapshots[j].TimeStamp)
+	})
+	return nil
+}
+
+// ShardMemory returns the used memory trend for a shard
+func (t *Timeline) ShardMemory(id ShardID) *Trend {
+	return t.trend(id.String(), func(c *ClusterInfo) (RAMFloat, bool) {
+		if shard := c.Shard(id); shard != nil {
+			return shard.UsedMemory, true
+		}
+		return 0, false
+	})
+}
+
+// NodeFreeRAM returns the free RAM trend for a node
+func (t *Timeline) NodeFreeRAM(id NodeID) *Trend {
+	return t.trend(id.String(), func(c *ClusterInfo) (RAMFloat, bool) {
+		if node := c.Node(id); node != nil {
+			return node.RedisRAM.Free, true
+		}
+		return 0, false
+	})
+}
+
+// ShardGrowth returns the used memory trend for every shard seen in the timeline
+func (t *Timeline) ShardGrowth() Trends {
+	trends := Trends{}
+	for _, id := range timelineIds(t, func(c *ClusterInfo) []ShardID {
+		ids := make([]ShardID, len(c.Shards))
+		for n, shard := range c.Shards {
+			ids[n] = shard.Id
+		}
+		return ids
+	}) {
+		trends = append(trends, t.ShardMemory(id))
+	}
+	return trends
+}
+
+// NodeRAMTrends returns the free RAM trend for every node seen in the timeline
+func (t *Timeline) NodeRAMTrends() Trends {
+	trends := Trends{}
+	for _, id := range timelineIds(t, func(c *ClusterInfo) []NodeID {
+		ids := make([]NodeID, len(c.Nodes))
+		for n, node := range c.Nodes {
+			ids[n] = node.Id
+		}
+		return ids
+	}) {
+		trends = append(trends, t.NodeFreeRAM(id))
+	}
+	return trends
+}
+
+// Moves returns every shard move in the timeline in the order they were seen
+func (t *Timeline) Moves() ShardMoves {
+	moves := ShardMoves{}
+	for n := 1; n < len(t.Snapshots); n++ {
+		before, after := t.Snapshots[n-1], t.Snapshots[n]
+		for _, shard := range after.Shards {
+			if previous := before.Shard(shard.Id); previous != nil && previous.NodeId != shard.NodeId {
+				moves = append(moves, &ShardMove{
+					Key:       t.Key,
+					Shard:     shard.Id,
+					DBId:      shard.DBId,
+					From:      previous.NodeId,
+					To:        shard.NodeId,
+					After:     before.TimeStamp,
+					TimeStamp: after.TimeStamp,
+				})
+			}
+		}
+	}
+	return moves
+}
+
+// ShardMoves returns the moves of a single shard
+func (t *Timeline) ShardMoves(id ShardID) ShardMoves {
+	moves := ShardMoves{}
+	for _, move := range t.Moves() {
+		if move.Shard == id {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+// MovedTo returns the most recent move of the shard to the node, if there was one.
+func (t *Timeline) MovedTo(shard ShardID, node NodeID) (*ShardMove, bool) {
+	moves := t.ShardMoves(shard)
+	for n := len(moves) - 1; n >= 0; n-- {
+		if moves[n].To == node {
+			return moves[n], true
+		}
+	}
+	return nil, false
+}
+
+// trend builds a trend from the snapshots where value returns true
+func (t *Timeline) trend(id string, value func(*ClusterInfo) (RAMFloat, bool)) *Trend {
+	trend := &Trend{Key: t.Key, Id: id, Points: TrendPoints{}}
+	for _, snapshot := range t.Snapshots {
+		if v, ok := value(snapshot); ok {
+			trend.Points = append(trend.Points, &TrendPoint{Key: t.Key, Id: id, TimeStamp: snapshot.TimeStamp, Value: v})
+		}
+	}
+
+	if len(trend.Points) > 0 {
+		first, last := trend.Points[0], trend.Points[len(trend.Points)-1]
+		trend.First = first.TimeStamp
+		trend.Last = last.TimeStamp
+		trend.Change = last.Value - first.Value
+		trend.Rate = trend.Points.rate()
+	}
+	return trend
+}
+
+// timelineIds returns the ids found in the snapshots in the order first seen
+func timelineIds[T comparable](t *Timeline, list func(*ClusterInfo) []T) []T {
+	ids := []T{}
+	seen := map[T]bool{}
+	for _, snapshot := range t.Snapshots {
+		for _, id := range list(snapshot) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// rate returns the least squares slope of the values per hour
+func (p TrendPoints) rate() RAMFloat {
+	if len(p) < 2 {
+		return 0
+	}
+
+	var meanX, meanY float64
+	for _, point := range p {
+		meanX += point.TimeStamp.Sub(p[0].TimeStamp).Hours()
+		meanY += float64(point.Value)
+	}
+	meanX /= float64(len(p))
+	meanY /= float64(len(p))
+
+	var covariance, variance float64
+	for _, point := range p {
+		dx := point.TimeStamp.Sub(p[0].TimeStamp).Hours() - meanX
+		covariance += dx * (float64(point.Value) - meanY)
+		variance += dx * dx
+	}
+
+	if variance == 0 {
+		return 0
+	}
+	return RAMFloat(covariance / variance)
+}
+
+// Points returns the points of every trend, for graphing
+func (t Trends) Points() TrendPoints {
+	points := TrendPoints{}
+	for _, trend := range t {
+		points = append(points, trend.Points...)
+	}
+	return points
+}
+
+func (t Trends) JSON() (string, error) {
+	if out, err := json.Marshal(t); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (t Trends) CSV(skipHeaders bool) (string, error) {
+	if skipHeaders {
+		return gocsv.MarshalStringWithoutHeaders(t)
+	} else {
+		return gocsv.MarshalString(t)
+	}
+}
+
+func (p TrendPoints) JSON() (string, error) {
+	if out, err := json.Marshal(p); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (p TrendPoints) CSV(skipHeaders bool) (string, error) {
+	if skipHeaders {
+		return gocsv.MarshalStringWithoutHeaders(p)
+	} else {
+		return gocsv.MarshalString(p)
+	}
+}
+
+func (m ShardMoves) JSON() (string, error) {
+	if out, err := json.Marshal(m); err != nil {
+		return "", err
+	} else {
+		return string(out), nil
+	}
+}
+
+func (m ShardMoves) CSV(skipHeaders bool) (string, error) {
+	if skipHeaders {
+		return gocsv.MarshalStringWithoutHeaders(m)
+	} else {
+		return gocsv.MarshalString(m)
+	}
+}
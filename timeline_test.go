@@ -0,0 +1,85 @@
+/*
+Copyright © 2024 Nic Gibson <nic.gibson@redis.com>
+*/
+package clusterinfo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// snapshot parses the test data and shifts its timestamp by offset
+func snapshot(t *testing.T, offset time.Duration) *ClusterInfo {
+	info, err := NewClusterInfo("test", bytes.NewReader(rladmin))
+	assert.Nil(t, err)
+	info.TimeStamp = info.TimeStamp.Add(offset)
+	return info
+}
+
+func TestTimeline(t *testing.T) {
+	first := snapshot(t, 0)
+	second := snapshot(t, time.Hour)
+	third := snapshot(t, 2*time.Hour)
+
+	second.Shard(5).UsedMemory += 1
+	second.Node(7).RedisRAM.Free -= 1
+	third.Shard(5).UsedMemory += 2
+	third.Shard(5).NodeId = 8
+	third.Reindex()
+
+	timeline := NewTimeline("test")
+	// added out of order
+	assert.Nil(t, timeline.Add(third, first, second))
+	assert.Equal(t, []*ClusterInfo{first, second, third}, timeline.Snapshots)
+
+	other := snapshot(t, 0)
+	other.Key = "other"
+	assert.NotNil(t, timeline.Add(snapshot(t, 4*time.Hour), other))
+	assert.Len(t, timeline.Snapshots, 3)
+	assert.NotPanics(t, func() {
+		assert.NotNil(t, timeline.Add(snapshot(t, 4*time.Hour), nil))
+	})
+	assert.Len(t, timeline.Snapshots, 3)
+
+	memory := timeline.ShardMemory(5)
+	assert.Len(t, memory.Points, 3)
+	assert.InDelta(t, 2.0, float64(memory.Change), 0.0001)
+	assert.InDelta(t, 1.0, float64(memory.Rate), 0.0001)
+	assert.Equal(t, first.TimeStamp, memory.First)
+	assert.Equal(t, third.TimeStamp, memory.Last)
+
+	free := timeline.NodeFreeRAM(7)
+	assert.InDelta(t, 0.0, float64(free.Change), 0.0001)
+	assert.InDelta(t, 105.77, float64(free.Points[1].Value), 0.0001)
+
+	assert.Len(t, timeline.ShardGrowth(), len(first.Shards))
+	assert.Len(t, timeline.NodeRAMTrends(), len(first.Nodes))
+
+	moves := timeline.Moves()
+	if assert.Len(t, moves, 1) {
+		assert.Equal(t, ShardID(5), moves[0].Shard)
+		assert.Equal(t, NodeID(7), moves[0].From)
+		assert.Equal(t, NodeID(8), moves[0].To)
+		assert.Equal(t, second.TimeStamp, moves[0].After)
+		assert.Equal(t, third.TimeStamp, moves[0].TimeStamp)
+	}
+
+	move, ok := timeline.MovedTo(5, 8)
+	assert.True(t, ok)
+	assert.Equal(t, third.TimeStamp, move.TimeStamp)
+	_, ok = timeline.MovedTo(5, 7)
+	assert.False(t, ok)
+
+	csv, err := Trends{memory}.Points().CSV(false)
+	assert.Nil(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(csv), "\n"), 4)
+
+	_, err = Trends{memory, free}.JSON()
+	assert.Nil(t, err)
+	_, err = moves.CSV(true)
+	assert.Nil(t, err)
+}